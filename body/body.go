@@ -18,3 +18,48 @@ type Body interface {
 	// 每个实现通常根据自身所需格式返回相应的 Reader
 	Encode() io.Reader
 }
+
+// BodyV2 在 Body 的基础上增加了可返回错误、可重复读取的编码方法
+// 由于 Body.Encode 的签名无法修改，BodyV2 通过 Open 返回 (io.ReadCloser, error)，
+// Request 会优先使用 Open 构建请求体，并将其作为 http.Request.GetBody 用于重定向与重试时重新读取
+// Open 返回的读取器同时实现 io.ReaderAt 与 Size() int64 时，Request 以这次 Open 的内容作为请求体长度并用于重新读取，
+// 不再调用 Length，重定向与重试时也不再调用 Open，每次发送只编码一次
+type BodyV2 interface {
+	Body
+	// Open 返回一个从头开始读取的请求体，每次调用都返回新的读取器
+	// 编码失败时返回错误，调用者负责关闭返回的 io.ReadCloser
+	Open() (io.ReadCloser, error)
+	// Length 返回请求体的字节长度，用于设置 Content-Length，未知时返回 -1
+	Length() int64
+}
+
+// sizedReader 为内存中的请求体内容，例如 *bytes.Reader 与 *strings.Reader
+type sizedReader interface {
+	io.Reader
+	io.ReaderAt
+	Size() int64
+}
+
+// memoryBody 为内存中请求体的读取器，保留 io.ReaderAt 与 Size 供 Request 使用
+type memoryBody struct {
+	sizedReader
+}
+
+func (memoryBody) Close() error {
+	return nil
+}
+
+// Errorer 是请求体可选实现的接口
+// Encode 无法返回错误，实现该接口的请求体可通过 Err 报告编码过程中发生的错误，
+// Request 在调用 Encode 后会检查该错误，避免发送空的请求体
+type Errorer interface {
+	// Err 返回最近一次 Encode 时发生的错误
+	Err() error
+}
+
+// Cloner 是请求体可选实现的接口
+// Request.Clone 通过 Clone 复制请求体，未实现该接口的请求体会在复制出的 Request 之间共享
+type Cloner interface {
+	// Clone 返回请求体的副本，对副本的修改不影响原请求体
+	Clone() Body
+}
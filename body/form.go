@@ -1,7 +1,7 @@
 package body
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -11,27 +11,38 @@ import (
 )
 
 // Form 用于构建 multipart/form-data 类型的表单请求体
-// 支持添加普通字段与文件字段，并最终编码为可用于 HTTP 请求的 io.Reader
+// 支持添加普通字段与文件字段，文件内容在发送时才从磁盘流式读取，不会整体加载到内存
 type Form struct {
-	dataBuf *bytes.Buffer
-	data    *multipart.Writer
+	boundary string
+	parts    []formPart
+	err      error
+}
+
+// formPart 表示表单中的一个字段
+// file 为空时表示普通字段，否则表示从该路径读取内容的文件字段
+type formPart struct {
+	name  string
+	value string
+	file  string
 }
 
 // NewFormData 创建一个新的 Form 实例
 // 返回的 Form 可用于设置字段、文件内容并生成 multipart/form-data 请求体
 func NewFormData() *Form {
-	bodyBuf := &bytes.Buffer{}
-	bodyWriter := multipart.NewWriter(bodyBuf)
 	return &Form{
-		dataBuf: bodyBuf,
-		data:    bodyWriter,
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
 	}
 }
 
 // SetBoundary 手动设置 multipart/form-data 边界值
 // 一般情况下不需要手动设置边界，除非对外兼容性有特殊要求
+// 边界值不合法时，错误会在发送请求时返回
 func (this *Form) SetBoundary(boundary string) *Form {
-	_ = this.data.SetBoundary(boundary)
+	if err := multipart.NewWriter(io.Discard).SetBoundary(boundary); err != nil {
+		this.err = err
+		return this
+	}
+	this.boundary = boundary
 	return this
 }
 
@@ -39,68 +50,161 @@ func (this *Form) SetBoundary(boundary string) *Form {
 // 参数 name 表示字段名，value 表示字段内容
 // 支持多次链式调用
 func (this *Form) SetData(name, value string) *Form {
-	_ = this.data.WriteField(name, value)
+	this.parts = append(this.parts, formPart{name: name, value: value})
 	return this
 }
 
 // SetFile 向表单中添加一个文件字段
 // 参数 name 为表单字段名，file 为本地文件路径
+// 文件不存在或无法读取时，错误会在发送请求时返回
 // 支持多次链式调用
 func (this *Form) SetFile(name, file string) *Form {
-	fd, err := os.Open(file)
+	info, err := os.Stat(file)
+	if err == nil && info.IsDir() {
+		err = errors.New("is a directory")
+	}
 	if err != nil {
-		panic("file does not exist")
+		if this.err == nil {
+			this.err = fmt.Errorf("form file %s: %v", file, err)
+		}
+		return this
 	}
-	defer fd.Close()
-	fileWriter, _ := this.data.CreateFormFile(name, filepath.Base(file))
-	_, _ = io.Copy(fileWriter, fd)
+	this.parts = append(this.parts, formPart{name: name, file: file})
 	return this
 }
 
-// GetData 返回当前 Form 的 multipart 数据文本形式
+// newWriter 创建一个使用当前边界值的 multipart.Writer
+func (this *Form) newWriter(w io.Writer) *multipart.Writer {
+	mw := multipart.NewWriter(w)
+	_ = mw.SetBoundary(this.boundary)
+	return mw
+}
+
+// writeTo 将完整的 multipart/form-data 内容写入 w，文件内容从磁盘逐块复制
+func (this *Form) writeTo(w io.Writer) error {
+	mw := this.newWriter(w)
+	for _, part := range this.parts {
+		if part.file == "" {
+			if err := mw.WriteField(part.name, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+		fileWriter, err := mw.CreateFormFile(part.name, filepath.Base(part.file))
+		if err != nil {
+			return err
+		}
+		fd, err := os.Open(part.file)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fileWriter, fd)
+		_ = fd.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// GetData 返回当前 Form 的 multipart 数据文本形式，文件字段不包含文件内容
 func (this *Form) GetData() string {
-	b := this.dataBuf.Bytes()
-	br := bytes.NewReader(b)
-	r := multipart.NewReader(br, this.data.Boundary())
-	form, err := r.ReadForm(32 << 20)
-	if err == nil {
-		var sb strings.Builder
-		boundary := this.data.Boundary()
-		header := fmt.Sprintf("--%s\r\n", boundary)
-		footer := fmt.Sprintf("--%s--", boundary)
-
-		for k, v := range form.Value {
-			sb.WriteString(header)
-			sb.WriteString(fmt.Sprintf(`Content-Disposition: form-data; name="%s"`, k))
+	var sb strings.Builder
+	header := fmt.Sprintf("--%s\r\n", this.boundary)
+	footer := fmt.Sprintf("--%s--", this.boundary)
+
+	for _, part := range this.parts {
+		sb.WriteString(header)
+		if part.file == "" {
+			sb.WriteString(fmt.Sprintf(`Content-Disposition: form-data; name="%s"`, part.name))
 			sb.WriteString("\r\n")
-			sb.WriteString(v[0])
+			sb.WriteString(part.value)
 			sb.WriteString("\r\n")
+			continue
 		}
-
-		for fk, fv := range form.File {
-			for _, fh := range fv {
-				sb.WriteString(header)
-				sb.WriteString(fmt.Sprintf(
-					`Content-Disposition: form-data; name="%s"; filename="%s"`, fk, fh.Filename))
-				sb.WriteString("\r\n")
-				sb.WriteString(fmt.Sprintf("Content-Type: %s\r\n", fh.Header.Get("Content-Type")))
-			}
-		}
-		sb.WriteString(footer)
-		return sb.String()
+		sb.WriteString(fmt.Sprintf(
+			`Content-Disposition: form-data; name="%s"; filename="%s"`, part.name, filepath.Base(part.file)))
+		sb.WriteString("\r\n")
+		sb.WriteString("Content-Type: application/octet-stream\r\n")
 	}
-	return ""
+	sb.WriteString(footer)
+	return sb.String()
 }
 
 // GetContentType 返回该表单对应的 Content-Type，包含 boundary
 func (this *Form) GetContentType() string {
-	return this.data.FormDataContentType()
+	return this.newWriter(io.Discard).FormDataContentType()
 }
 
-// Encode 关闭 multipart.Writer 并返回完整的 multipart/form-data 编码内容
-// 返回的 io.Reader 可直接作为 HTTP 请求 body
-// 注意：调用 Encode() 后将无法再次向 Form 中添加字段
+// Encode 返回完整的 multipart/form-data 编码内容
+// 返回的 io.Reader 可直接作为 HTTP 请求 body，内容在读取时通过 io.Pipe 流式生成
+// 多次调用 Encode() 会返回相同的内容，便于请求重试时重新发送
+// 编码过程中的错误会在读取时返回，也可通过 Err 获取
 func (this *Form) Encode() io.Reader {
-	_ = this.data.Close()
-	return io.NopCloser(this.dataBuf)
+	r, err := this.Open()
+	if err != nil {
+		pr, pw := io.Pipe()
+		_ = pw.CloseWithError(err)
+		return pr
+	}
+	return r
+}
+
+// Open 返回一个流式生成 multipart/form-data 内容的读取器
+// 文件内容在读取时才从磁盘复制，适用于上传大文件
+func (this *Form) Open() (io.ReadCloser, error) {
+	if this.err != nil {
+		return nil, this.err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(this.writeTo(pw))
+	}()
+	return pr, nil
+}
+
+// Length 返回编码后内容的字节长度，文件大小以调用时的文件状态为准
+// 无法获取文件大小时返回 -1
+func (this *Form) Length() int64 {
+	if this.err != nil {
+		return -1
+	}
+	cw := &countWriter{}
+	mw := this.newWriter(cw)
+	for _, part := range this.parts {
+		if part.file == "" {
+			_ = mw.WriteField(part.name, part.value)
+			continue
+		}
+		info, err := os.Stat(part.file)
+		if err != nil {
+			return -1
+		}
+		_, _ = mw.CreateFormFile(part.name, filepath.Base(part.file))
+		cw.n += info.Size()
+	}
+	_ = mw.Close()
+	return cw.n
+}
+
+// Err 返回添加字段或设置边界时发生的错误
+func (this *Form) Err() error {
+	return this.err
+}
+
+// countWriter 只统计写入的字节数
+type countWriter struct {
+	n int64
+}
+
+func (this *countWriter) Write(p []byte) (int, error) {
+	this.n += int64(len(p))
+	return len(p), nil
+}
+
+// Clone 返回当前表单的副本，文件字段仍从相同的路径读取
+func (this *Form) Clone() Body {
+	c := *this
+	c.parts = append([]formPart(nil), this.parts...)
+	return &c
 }
@@ -0,0 +1,123 @@
+package body
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// JsonBody 用于构建 application/json 类型的请求体
+// 传入的值在 Encode 时才会被序列化，序列化失败时错误可通过 Err 获取
+type JsonBody struct {
+	value       any
+	prefix      string
+	indent      string
+	escapeHTML  bool
+	contentType string
+	encoder     func(v any) ([]byte, error)
+	err         error
+}
+
+// NewJson 创建一个 JSON 请求体，参数 v 为需要序列化的值
+// 默认不缩进并转义 HTML 字符，与 json.Marshal 的行为一致
+func NewJson(v any) *JsonBody {
+	return &JsonBody{
+		value:       v,
+		escapeHTML:  true,
+		contentType: Json,
+	}
+}
+
+// SetIndent 设置序列化时的前缀与缩进，与 json.MarshalIndent 的参数含义相同
+func (this *JsonBody) SetIndent(prefix, indent string) *JsonBody {
+	this.prefix = prefix
+	this.indent = indent
+	return this
+}
+
+// SetEscapeHTML 设置是否将 <、>、& 转义为 \u003c 等形式，默认转义
+func (this *JsonBody) SetEscapeHTML(escape bool) *JsonBody {
+	this.escapeHTML = escape
+	return this
+}
+
+// SetEncoder 设置自定义序列化函数，例如使用第三方 JSON 库
+// 设置后 SetIndent 与 SetEscapeHTML 不再生效
+func (this *JsonBody) SetEncoder(f func(v any) ([]byte, error)) *JsonBody {
+	this.encoder = f
+	return this
+}
+
+// SetContentType 设置自定义的 Content-Type，例如 "application/vnd.api+json"
+func (this *JsonBody) SetContentType(contentType string) *JsonBody {
+	this.contentType = contentType
+	return this
+}
+
+// marshal 序列化当前值并记录错误
+func (this *JsonBody) marshal() []byte {
+	var (
+		data []byte
+		err  error
+	)
+	if this.encoder != nil {
+		data, err = this.encoder(this.value)
+	} else {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent(this.prefix, this.indent)
+		enc.SetEscapeHTML(this.escapeHTML)
+		err = enc.Encode(this.value)
+		data = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+	this.err = err
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// GetData 返回序列化后的 JSON 文本，序列化失败时返回空字符串
+func (this *JsonBody) GetData() string {
+	return string(this.marshal())
+}
+
+// GetContentType 返回该请求体的 Content-Type
+func (this *JsonBody) GetContentType() string {
+	return this.contentType
+}
+
+// Encode 序列化当前值并返回 io.Reader，用于构建 HTTP 请求的 body
+func (this *JsonBody) Encode() io.Reader {
+	return bytes.NewReader(this.marshal())
+}
+
+// Open 序列化当前值并返回读取器，序列化失败时返回错误
+func (this *JsonBody) Open() (io.ReadCloser, error) {
+	data := this.marshal()
+	if this.err != nil {
+		return nil, this.err
+	}
+	return memoryBody{bytes.NewReader(data)}, nil
+}
+
+// Length 返回序列化后的字节长度，序列化失败时返回 -1
+func (this *JsonBody) Length() int64 {
+	data := this.marshal()
+	if this.err != nil {
+		return -1
+	}
+	return int64(len(data))
+}
+
+// Err 返回最近一次序列化时发生的错误
+func (this *JsonBody) Err() error {
+	return this.err
+}
+
+// Clone 返回当前请求体的副本，需要序列化的值本身不会被复制
+func (this *JsonBody) Clone() Body {
+	c := *this
+	c.err = nil
+	return &c
+}
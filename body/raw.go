@@ -55,3 +55,19 @@ func (this *Raw) GetContentType() string {
 func (this *Raw) Encode() io.Reader {
 	return strings.NewReader(this.data)
 }
+
+// Open 返回当前数据的读取器
+func (this *Raw) Open() (io.ReadCloser, error) {
+	return memoryBody{strings.NewReader(this.data)}, nil
+}
+
+// Length 返回当前数据的字节长度
+func (this *Raw) Length() int64 {
+	return int64(len(this.data))
+}
+
+// Clone 返回当前请求体的副本
+func (this *Raw) Clone() Body {
+	c := *this
+	return &c
+}
@@ -42,3 +42,22 @@ func (this *Url) GetContentType() string {
 func (this *Url) Encode() io.Reader {
 	return strings.NewReader(this.data.Encode())
 }
+
+// Open 返回编码后表单内容的读取器
+func (this *Url) Open() (io.ReadCloser, error) {
+	return memoryBody{strings.NewReader(this.data.Encode())}, nil
+}
+
+// Length 返回编码后表单内容的字节长度
+func (this *Url) Length() int64 {
+	return int64(len(this.data.Encode()))
+}
+
+// Clone 返回当前表单的副本
+func (this *Url) Clone() Body {
+	data := url.Values{}
+	for k, v := range *this.data {
+		data[k] = append([]string(nil), v...)
+	}
+	return &Url{data: &data}
+}
@@ -0,0 +1,119 @@
+package body
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// XmlBody 用于构建 application/xml 类型的请求体
+// 传入的值在 Encode 时才会被序列化，序列化失败时错误可通过 Err 获取
+type XmlBody struct {
+	value       any
+	prefix      string
+	indent      string
+	header      bool
+	contentType string
+	encoder     func(v any) ([]byte, error)
+	err         error
+}
+
+// NewXml 创建一个 XML 请求体，参数 v 为需要序列化的值
+// 默认不缩进且不输出 XML 声明，与 xml.Marshal 的行为一致
+func NewXml(v any) *XmlBody {
+	return &XmlBody{
+		value:       v,
+		contentType: Xml,
+	}
+}
+
+// SetIndent 设置序列化时的前缀与缩进，与 xml.MarshalIndent 的参数含义相同
+func (this *XmlBody) SetIndent(prefix, indent string) *XmlBody {
+	this.prefix = prefix
+	this.indent = indent
+	return this
+}
+
+// SetHeader 设置是否在内容前输出标准 XML 声明 xml.Header
+func (this *XmlBody) SetHeader(header bool) *XmlBody {
+	this.header = header
+	return this
+}
+
+// SetEncoder 设置自定义序列化函数，设置后 SetIndent 不再生效
+func (this *XmlBody) SetEncoder(f func(v any) ([]byte, error)) *XmlBody {
+	this.encoder = f
+	return this
+}
+
+// SetContentType 设置自定义的 Content-Type，例如 "text/xml"
+func (this *XmlBody) SetContentType(contentType string) *XmlBody {
+	this.contentType = contentType
+	return this
+}
+
+// marshal 序列化当前值并记录错误
+func (this *XmlBody) marshal() []byte {
+	var (
+		data []byte
+		err  error
+	)
+	if this.encoder != nil {
+		data, err = this.encoder(this.value)
+	} else {
+		data, err = xml.MarshalIndent(this.value, this.prefix, this.indent)
+	}
+	this.err = err
+	if err != nil {
+		return nil
+	}
+	if this.header {
+		data = append([]byte(xml.Header), data...)
+	}
+	return data
+}
+
+// GetData 返回序列化后的 XML 文本，序列化失败时返回空字符串
+func (this *XmlBody) GetData() string {
+	return string(this.marshal())
+}
+
+// GetContentType 返回该请求体的 Content-Type
+func (this *XmlBody) GetContentType() string {
+	return this.contentType
+}
+
+// Encode 序列化当前值并返回 io.Reader，用于构建 HTTP 请求的 body
+func (this *XmlBody) Encode() io.Reader {
+	return bytes.NewReader(this.marshal())
+}
+
+// Open 序列化当前值并返回读取器，序列化失败时返回错误
+func (this *XmlBody) Open() (io.ReadCloser, error) {
+	data := this.marshal()
+	if this.err != nil {
+		return nil, this.err
+	}
+	return memoryBody{bytes.NewReader(data)}, nil
+}
+
+// Length 返回序列化后的字节长度，序列化失败时返回 -1
+func (this *XmlBody) Length() int64 {
+	data := this.marshal()
+	if this.err != nil {
+		return -1
+	}
+	return int64(len(data))
+}
+
+// Err 返回最近一次序列化时发生的错误
+func (this *XmlBody) Err() error {
+	return this.err
+}
+
+// Clone 返回当前请求体的副本，需要序列化的值本身不会被复制
+func (this *XmlBody) Clone() Body {
+	c := *this
+	c.err = nil
+	return &c
+}
@@ -0,0 +1,310 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 在目标主机的熔断器处于打开状态时返回，请求不会被发送
+// 可通过 errors.Is(err, httpc.ErrCircuitOpen) 判断
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState 熔断器的状态
+type CircuitState int
+
+const (
+	// CircuitClosed 关闭状态，请求正常发送并统计失败率
+	CircuitClosed CircuitState = iota
+	// CircuitOpen 打开状态，请求直接返回 ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen 半开状态，冷却时间结束后允许少量探测请求通过
+	CircuitHalfOpen
+)
+
+func (this CircuitState) String() string {
+	switch this {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// circuit 为单个主机的熔断状态
+type circuit struct {
+	state       CircuitState
+	generation  uint64
+	windowStart time.Time
+	total       int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+	lastSeen    time.Time
+}
+
+// CircuitBreaker 定义按主机熔断的策略
+// 关闭状态下统计窗口内的失败率，达到阈值后打开；打开状态持续冷却时间后进入半开状态，
+// 半开状态下的探测请求全部成功则关闭，任意一次失败则重新打开
+type CircuitBreaker struct {
+	mu            sync.Mutex
+	window        time.Duration
+	minRequests   int
+	failureRatio  float64
+	coolDown      time.Duration
+	halfOpenMax   int
+	failureIf     func(resp *http.Response, err error) bool
+	onStateChange func(host string, from, to CircuitState)
+	hosts         map[string]*circuit
+	lastSweep     time.Time
+}
+
+// NewCircuitBreaker 创建一个默认配置的熔断器
+// 默认统计窗口 10s，窗口内至少 10 个请求且失败率达到 50% 时打开，冷却 30s，半开状态允许 1 个探测请求
+// 默认将网络错误与 5xx 状态码视为失败
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		window:       10 * time.Second,
+		minRequests:  10,
+		failureRatio: 0.5,
+		coolDown:     30 * time.Second,
+		halfOpenMax:  1,
+		hosts:        make(map[string]*circuit),
+		lastSweep:    time.Now(),
+	}
+}
+
+// SetWindow 设置关闭状态下统计失败率的时间窗口
+func (this *CircuitBreaker) SetWindow(d time.Duration) *CircuitBreaker {
+	this.window = d
+	return this
+}
+
+// SetMinRequests 设置窗口内触发熔断所需的最少请求数，小于 1 时按 1 处理
+func (this *CircuitBreaker) SetMinRequests(n int) *CircuitBreaker {
+	if n < 1 {
+		n = 1
+	}
+	this.minRequests = n
+	return this
+}
+
+// SetFailureRatio 设置触发熔断的失败率阈值，取值范围 (0, 1]
+func (this *CircuitBreaker) SetFailureRatio(ratio float64) *CircuitBreaker {
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	this.failureRatio = ratio
+	return this
+}
+
+// SetCoolDown 设置熔断打开后进入半开状态前的冷却时间
+func (this *CircuitBreaker) SetCoolDown(d time.Duration) *CircuitBreaker {
+	this.coolDown = d
+	return this
+}
+
+// SetHalfOpenRequests 设置半开状态下允许通过的探测请求数，这些请求全部成功后熔断器关闭，小于 1 时按 1 处理
+func (this *CircuitBreaker) SetHalfOpenRequests(n int) *CircuitBreaker {
+	if n < 1 {
+		n = 1
+	}
+	this.halfOpenMax = n
+	return this
+}
+
+// SetFailureIf 设置自定义的失败判断函数，设置后替代默认的判断规则
+func (this *CircuitBreaker) SetFailureIf(f func(resp *http.Response, err error) bool) *CircuitBreaker {
+	this.failureIf = f
+	return this
+}
+
+// SetStateChange 设置熔断器状态变化时的回调函数，可用于告警或记录日志
+// 回调在请求所在的协程中同步执行
+func (this *CircuitBreaker) SetStateChange(f func(host string, from, to CircuitState)) *CircuitBreaker {
+	this.onStateChange = f
+	return this
+}
+
+// State 返回指定主机当前的熔断状态
+func (this *CircuitBreaker) State(host string) CircuitState {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	c, ok := this.hosts[strings.ToLower(host)]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= this.coolDown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// Reset 将指定主机的熔断器恢复为关闭状态
+func (this *CircuitBreaker) Reset(host string) {
+	host = strings.ToLower(host)
+	this.mu.Lock()
+	c, ok := this.hosts[host]
+	from := CircuitClosed
+	if ok {
+		from = c.state
+		delete(this.hosts, host)
+	}
+	this.mu.Unlock()
+	this.notify(host, from, CircuitClosed)
+}
+
+// notify 在状态发生变化时调用回调函数，调用者不能持有锁
+func (this *CircuitBreaker) notify(host string, from, to CircuitState) {
+	if from != to && this.onStateChange != nil {
+		this.onStateChange(host, from, to)
+	}
+}
+
+// isFailure 判断请求结果是否计为失败
+func (this *CircuitBreaker) isFailure(resp *http.Response, err error) bool {
+	if this.failureIf != nil {
+		return this.failureIf(resp, err)
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// setState 切换状态并清空统计，调用者需持有锁
+func (this *CircuitBreaker) setState(c *circuit, state CircuitState, now time.Time) {
+	c.state = state
+	c.generation++
+	c.windowStart = now
+	c.total, c.failures, c.probes, c.successes = 0, 0, 0, 0
+	if state == CircuitOpen {
+		c.openedAt = now
+	}
+}
+
+// allow 判断请求是否可以发送，返回请求发送时的统计代数
+func (this *CircuitBreaker) allow(host string) (uint64, error) {
+	this.mu.Lock()
+	now := time.Now()
+	if now.Sub(this.lastSweep) >= stateSweepInterval {
+		this.sweep(now)
+	}
+	c, ok := this.hosts[host]
+	if !ok {
+		c = &circuit{windowStart: now}
+		this.hosts[host] = c
+	}
+	c.lastSeen = now
+	from := c.state
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= this.coolDown {
+		this.setState(c, CircuitHalfOpen, now)
+	}
+	var err error
+	switch c.state {
+	case CircuitOpen:
+		err = fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	case CircuitHalfOpen:
+		if c.probes >= this.halfOpenMax {
+			err = fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		} else {
+			c.probes++
+		}
+	}
+	to, generation := c.state, c.generation
+	this.mu.Unlock()
+
+	this.notify(host, from, to)
+	return generation, err
+}
+
+// sweep 删除一段时间内没有请求且统计窗口已过期的关闭状态，避免访问大量主机时状态无限增长，调用者需持有锁
+// 打开与半开状态会被保留，被删除主机上仍在进行中的请求结果会被忽略
+func (this *CircuitBreaker) sweep(now time.Time) {
+	this.lastSweep = now
+	for host, c := range this.hosts {
+		if c.state == CircuitClosed && now.Sub(c.lastSeen) >= stateSweepInterval &&
+			(this.window <= 0 || now.Sub(c.windowStart) >= this.window) {
+			delete(this.hosts, host)
+		}
+	}
+}
+
+// record 记录请求结果并根据失败率切换状态，状态已变化后返回的旧请求结果会被忽略
+func (this *CircuitBreaker) record(host string, generation uint64, failed bool) {
+	this.mu.Lock()
+	now := time.Now()
+	c, ok := this.hosts[host]
+	if !ok || c.generation != generation {
+		this.mu.Unlock()
+		return
+	}
+	from := c.state
+	switch c.state {
+	case CircuitClosed:
+		if this.window > 0 && now.Sub(c.windowStart) >= this.window {
+			c.windowStart = now
+			c.total, c.failures = 0, 0
+		}
+		c.total++
+		if failed {
+			c.failures++
+		}
+		if c.total >= this.minRequests && float64(c.failures)/float64(c.total) >= this.failureRatio {
+			this.setState(c, CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			this.setState(c, CircuitOpen, now)
+		} else if c.successes++; c.successes >= this.halfOpenMax {
+			this.setState(c, CircuitClosed, now)
+		}
+	}
+	to := c.state
+	this.mu.Unlock()
+
+	this.notify(host, from, to)
+}
+
+// wrap 返回带熔断的 RoundTripper
+func (this *CircuitBreaker) wrap(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		generation, err := this.allow(host)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := next.RoundTrip(req)
+		if err != nil && req.Context().Err() != nil && errors.Is(err, context.Canceled) {
+			// 调用方主动取消的请求不计入统计，但需要释放半开状态的探测名额
+			this.release(host, generation)
+			return resp, err
+		}
+		this.record(host, generation, this.isFailure(resp, err))
+		return resp, err
+	})
+}
+
+// release 释放半开状态下未产生结果的探测名额
+func (this *CircuitBreaker) release(host string, generation uint64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if c, ok := this.hosts[host]; ok && c.generation == generation && c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// SetCircuitBreaker 设置客户端按主机熔断的策略，传入 nil 表示关闭熔断
+// 熔断器打开时请求直接返回 ErrCircuitOpen，不会等待超时，也不会触发重试
+func (this *HttpClient) SetCircuitBreaker(b *CircuitBreaker) *HttpClient {
+	this.breaker = b
+	return this
+}
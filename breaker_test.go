@@ -0,0 +1,42 @@
+package httpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerSweep(t *testing.T) {
+	b := NewCircuitBreaker().SetWindow(time.Second).SetMinRequests(1)
+	now := time.Now()
+
+	for _, host := range []string{"idle.example.com", "recent.example.com", "open.example.com"} {
+		if _, err := b.allow(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gen, _ := b.allow("open.example.com")
+	b.record("open.example.com", gen, true)
+	if state := b.State("open.example.com"); state != CircuitOpen {
+		t.Fatalf("open.example.com is %v", state)
+	}
+
+	b.mu.Lock()
+	b.hosts["idle.example.com"].lastSeen = now.Add(-2 * stateSweepInterval)
+	b.hosts["idle.example.com"].windowStart = now.Add(-2 * stateSweepInterval)
+	b.hosts["open.example.com"].lastSeen = now.Add(-2 * stateSweepInterval)
+	b.sweep(now)
+	_, idle := b.hosts["idle.example.com"]
+	_, recent := b.hosts["recent.example.com"]
+	_, open := b.hosts["open.example.com"]
+	b.mu.Unlock()
+
+	if idle {
+		t.Error("idle closed circuit not evicted")
+	}
+	if !recent {
+		t.Error("recently used circuit evicted")
+	}
+	if !open {
+		t.Error("open circuit evicted")
+	}
+}
@@ -0,0 +1,488 @@
+package httpc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CacheHeader 为缓存层添加到响应中的头信息，值为 CacheHit、CacheRevalidated、CacheStale 或 CacheMiss
+	CacheHeader = "X-Httpc-Cache"
+	// CacheHit 表示响应直接来自新鲜的缓存
+	CacheHit = "HIT"
+	// CacheRevalidated 表示缓存经服务端 304 响应确认后返回
+	CacheRevalidated = "REVALIDATED"
+	// CacheStale 表示请求失败时返回了过期的缓存（stale-if-error）
+	CacheStale = "STALE"
+	// CacheMiss 表示响应来自服务端
+	CacheMiss = "MISS"
+
+	// cacheRequestTime 与 cacheResponseTime 为缓存内部记录请求与响应时间的头信息
+	cacheRequestTime  = "X-Httpc-Request-Time"
+	cacheResponseTime = "X-Httpc-Response-Time"
+	// cacheVaryPrefix 为缓存内部记录 Vary 对应请求头的前缀
+	cacheVaryPrefix = "X-Httpc-Vary-"
+	// defaultCacheMaxBodySize 为默认可缓存的最大响应体大小
+	defaultCacheMaxBodySize = 10 << 20
+)
+
+// uncachedHeaders 为不随缓存保存的响应头：逐跳头信息与 Set-Cookie（RFC 9111 3.1）
+// Set-Cookie 若随缓存返回会被 CookieJar 重新写入，覆盖之后服务端设置的新 Cookie
+var uncachedHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Set-Cookie", "Set-Cookie2",
+}
+
+// heuristicStatus 为可以根据 Last-Modified 启发式计算新鲜期的状态码（RFC 9110 15.1）
+var heuristicStatus = map[int]bool{
+	200: true, 203: true, 204: true, 206: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// HttpCache 实现 RFC 9111 私有缓存
+// 支持 Cache-Control、Expires、基于 ETag 与 Last-Modified 的 If-None-Match/If-Modified-Since 再验证、Vary 以及 stale-if-error
+// 只缓存 GET 请求，带 Range 的请求不经过缓存，POST、PUT、PATCH、DELETE 等请求成功后会使对应 URL 的缓存失效
+// 缓存按 URL 以及请求的 Authorization 与 Cookie 区分，共享缓存的多个 Session 不会读取到彼此的响应；
+// 携带 Authorization 的请求只有在响应包含 public 或 s-maxage 时才会被缓存
+type HttpCache struct {
+	store        CacheStore
+	staleIfError time.Duration
+	maxBodySize  int64
+}
+
+// NewHttpCache 创建 HTTP 缓存，store 为 nil 时使用 64MB 的内存缓存
+func NewHttpCache(store CacheStore) *HttpCache {
+	if store == nil {
+		store = NewMemoryCacheStore(64 << 20)
+	}
+	return &HttpCache{store: store, maxBodySize: defaultCacheMaxBodySize}
+}
+
+// SetStaleIfError 设置请求失败或服务端返回 500、502、503、504 时，可以返回已过期多长时间以内的缓存
+// 响应头中的 stale-if-error 指令优先级更高，must-revalidate 与 no-cache 的缓存不会以过期状态返回
+func (this *HttpCache) SetStaleIfError(d time.Duration) *HttpCache {
+	this.staleIfError = d
+	return this
+}
+
+// SetMaxBodySize 设置可缓存的最大响应体字节数，超过的响应不会被缓存，默认 10MB，小于等于 0 时不限制
+func (this *HttpCache) SetMaxBodySize(n int64) *HttpCache {
+	this.maxBodySize = n
+	return this
+}
+
+// Delete 删除指定 URL 的缓存，携带 Authorization 或 Cookie 的请求保存的缓存不受影响
+func (this *HttpCache) Delete(rawUrl string) {
+	this.store.Delete(rawUrl)
+}
+
+// SetCache 为客户端设置 HTTP 缓存，传入 nil 表示关闭缓存
+// 缓存位于客户端中间件之内、熔断与限速之外，命中缓存的请求不会受到熔断与限速的影响
+func (this *HttpClient) SetCache(c *HttpCache) *HttpClient {
+	this.cache = c
+	return this
+}
+
+// cacheControl 为解析后的 Cache-Control 指令，名称为小写
+type cacheControl map[string]string
+
+// parseCacheControl 解析头信息中的 Cache-Control 指令
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, item := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(item), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// has 判断是否包含指令
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// duration 返回以秒为单位的指令值，不存在或无法解析时返回 false
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	value, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// cachedResponse 为从缓存中读取的响应
+type cachedResponse struct {
+	resp         *http.Response
+	body         []byte
+	requestTime  time.Time
+	responseTime time.Time
+}
+
+// age 计算缓存的当前年龄（RFC 9111 4.2.3）
+func (this *cachedResponse) age(now time.Time) time.Duration {
+	apparent := time.Duration(0)
+	if date, err := http.ParseTime(this.resp.Header.Get("Date")); err == nil && this.responseTime.After(date) {
+		apparent = this.responseTime.Sub(date)
+	}
+	corrected := this.responseTime.Sub(this.requestTime)
+	if seconds, err := strconv.ParseInt(this.resp.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		corrected += time.Duration(seconds) * time.Second
+	}
+	return max(apparent, corrected) + now.Sub(this.responseTime)
+}
+
+// lifetime 计算缓存的新鲜期（RFC 9111 4.2.1），没有显式过期时间时使用 Last-Modified 的 10% 作为启发式新鲜期
+func (this *cachedResponse) lifetime() time.Duration {
+	header := this.resp.Header
+	if maxAge, ok := parseCacheControl(header).duration("max-age"); ok {
+		return maxAge
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = this.responseTime
+	}
+	if value := header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil || !expires.After(date) {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && heuristicStatus[this.resp.StatusCode] && date.After(modified) {
+		return date.Sub(modified) / 10
+	}
+	return 0
+}
+
+// serve 根据缓存构造返回给调用方的响应
+func (this *cachedResponse) serve(req *http.Request, status string) *http.Response {
+	resp := *this.resp
+	resp.Header = this.resp.Header.Clone()
+	resp.Header.Set("Age", strconv.FormatInt(int64(this.age(time.Now())/time.Second), 10))
+	resp.Header.Set(CacheHeader, status)
+	resp.Body = io.NopCloser(bytes.NewReader(this.body))
+	resp.ContentLength = int64(len(this.body))
+	resp.Request = req
+	return &resp
+}
+
+// cacheKey 返回请求的缓存键，请求携带 Authorization 或 Cookie 时附加其摘要，使不同凭据的响应分开保存
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+	auth, cookie := req.Header.Values("Authorization"), req.Header.Values("Cookie")
+	if len(auth) == 0 && len(cookie) == 0 {
+		return key
+	}
+	h := sha256.New()
+	for _, v := range auth {
+		_, _ = io.WriteString(h, "a:"+v+"\n")
+	}
+	for _, v := range cookie {
+		_, _ = io.WriteString(h, "c:"+v+"\n")
+	}
+	return key + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+// load 读取请求对应的缓存，缓存不存在、无法解析或 Vary 对应的请求头不一致时返回 nil
+func (this *HttpCache) load(req *http.Request) *cachedResponse {
+	data, ok := this.store.Get(cacheKey(req))
+	if !ok {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil
+	}
+
+	cached := &cachedResponse{resp: resp, body: body}
+	requestTime, err1 := strconv.ParseInt(resp.Header.Get(cacheRequestTime), 10, 64)
+	responseTime, err2 := strconv.ParseInt(resp.Header.Get(cacheResponseTime), 10, 64)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	cached.requestTime = time.Unix(0, requestTime)
+	cached.responseTime = time.Unix(0, responseTime)
+
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" || req.Header.Get(name) != resp.Header.Get(cacheVaryPrefix+name) {
+			return nil
+		}
+	}
+	for name := range resp.Header {
+		if strings.HasPrefix(name, "X-Httpc-") {
+			resp.Header.Del(name)
+		}
+	}
+	stripUncached(resp.Header)
+	return cached
+}
+
+// stripUncached 删除不随缓存保存的响应头，包括 Connection 中列出的头信息
+func stripUncached(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range uncachedHeaders {
+		header.Del(name)
+	}
+}
+
+// save 保存响应到缓存，同时记录请求与响应时间以及 Vary 对应的请求头
+func (this *HttpCache) save(req *http.Request, statusCode int, header http.Header, body []byte, requestTime, responseTime time.Time) {
+	header = header.Clone()
+	header.Del(CacheHeader)
+	stripUncached(header)
+	header.Set(cacheRequestTime, strconv.FormatInt(requestTime.UnixNano(), 10))
+	header.Set(cacheResponseTime, strconv.FormatInt(responseTime.UnixNano(), 10))
+	for _, name := range varyHeaders(header) {
+		if value := req.Header.Get(name); value != "" {
+			header.Set(cacheVaryPrefix+name, value)
+		}
+	}
+	stored := &http.Response{
+		StatusCode:    statusCode,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	var buf bytes.Buffer
+	if stored.Write(&buf) == nil {
+		this.store.Set(cacheKey(req), buf.Bytes())
+	}
+}
+
+// varyHeaders 返回 Vary 中列出的请求头名称
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// storable 判断响应是否可以被缓存（RFC 9111 3），携带 Authorization 的请求需要响应显式允许（RFC 9111 3.5）
+func storable(req *http.Request, reqCC cacheControl, resp *http.Response) bool {
+	if resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusNotModified || reqCC.has("no-store") {
+		return false
+	}
+	respCC := parseCacheControl(resp.Header)
+	if respCC.has("no-store") {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" && !respCC.has("public") && !respCC.has("s-maxage") {
+		return false
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	if respCC.has("max-age") || resp.Header.Get("Expires") != "" || respCC.has("public") {
+		return true
+	}
+	return heuristicStatus[resp.StatusCode]
+}
+
+// wrap 返回带缓存的 RoundTripper
+func (this *HttpCache) wrap(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet {
+			resp, err := next.RoundTrip(req)
+			if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions && resp.StatusCode < 400 {
+				this.store.Delete(req.URL.String())
+				this.store.Delete(cacheKey(req))
+			}
+			return resp, err
+		}
+		reqCC := parseCacheControl(req.Header)
+		if req.Header.Get("Range") != "" || reqCC.has("no-store") {
+			return next.RoundTrip(req)
+		}
+
+		cached := this.load(req)
+		if cached == nil {
+			if reqCC.has("only-if-cached") {
+				return gatewayTimeout(req), nil
+			}
+			return this.fetch(req, reqCC, next, nil)
+		}
+
+		now := time.Now()
+		age, lifetime := cached.age(now), cached.lifetime()
+		respCC := parseCacheControl(cached.resp.Header)
+		fresh := age < lifetime
+		if maxAge, ok := reqCC.duration("max-age"); ok && age > maxAge {
+			fresh = false
+		}
+		if minFresh, ok := reqCC.duration("min-fresh"); ok && lifetime-age < minFresh {
+			fresh = false
+		}
+		revalidate := reqCC.has("no-cache") || respCC.has("no-cache")
+		if !fresh && !revalidate && reqCC.has("max-stale") && !respCC.has("must-revalidate") {
+			maxStale, ok := reqCC.duration("max-stale")
+			fresh = !ok || age-lifetime <= maxStale
+		}
+		if fresh && !revalidate {
+			return cached.serve(req, CacheHit), nil
+		}
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(req), nil
+		}
+		return this.fetch(req, reqCC, next, cached)
+	})
+}
+
+// fetch 向服务端发送请求，存在缓存时附加再验证的条件请求头
+func (this *HttpCache) fetch(req *http.Request, reqCC cacheControl, next http.RoundTripper, cached *cachedResponse) (*http.Response, error) {
+	outReq := req
+	if cached != nil && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		etag, modified := cached.resp.Header.Get("ETag"), cached.resp.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			outReq = req.Clone(req.Context())
+			if etag != "" {
+				outReq.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				outReq.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := next.RoundTrip(outReq)
+	responseTime := time.Now()
+
+	if err != nil || isServerError(resp.StatusCode) {
+		if cached != nil && this.canServeStale(cached, reqCC, responseTime) {
+			if resp != nil {
+				drainBody(resp)
+			}
+			return cached.serve(req, CacheStale), nil
+		}
+		return resp, err
+	}
+
+	if cached != nil && outReq != req && resp.StatusCode == http.StatusNotModified {
+		for name, values := range resp.Header {
+			if name != "Content-Length" {
+				cached.resp.Header[name] = values
+			}
+		}
+		drainBody(resp)
+		cached.requestTime, cached.responseTime = requestTime, responseTime
+		this.save(req, cached.resp.StatusCode, cached.resp.Header, cached.body, requestTime, responseTime)
+		return cached.serve(req, CacheRevalidated), nil
+	}
+
+	// 响应头在调用方读取响应体时可能被修改，例如解压时会删除 Content-Encoding，因此先保存副本
+	statusCode, header := resp.StatusCode, resp.Header.Clone()
+	if !storable(req, reqCC, resp) {
+		if cached != nil {
+			this.store.Delete(cacheKey(req))
+		}
+	} else if resp.Body == nil || resp.Body == http.NoBody {
+		this.save(req, statusCode, header, nil, requestTime, responseTime)
+	} else if this.maxBodySize <= 0 || resp.ContentLength <= this.maxBodySize {
+		resp.Body = &cachingBody{
+			ReadCloser: resp.Body,
+			limit:      this.maxBodySize,
+			done: func(body []byte) {
+				this.save(req, statusCode, header, body, requestTime, responseTime)
+			},
+		}
+	}
+	resp.Header.Set(CacheHeader, CacheMiss)
+	return resp, nil
+}
+
+// canServeStale 判断请求失败时是否可以返回过期的缓存
+func (this *HttpCache) canServeStale(cached *cachedResponse, reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(cached.resp.Header)
+	if respCC.has("must-revalidate") || respCC.has("no-cache") {
+		return false
+	}
+	window := this.staleIfError
+	if d, ok := respCC.duration("stale-if-error"); ok {
+		window = d
+	}
+	if d, ok := reqCC.duration("stale-if-error"); ok {
+		window = d
+	}
+	return cached.age(now)-cached.lifetime() <= window
+}
+
+// isServerError 判断状态码是否属于允许返回过期缓存的服务端错误
+func isServerError(code int) bool {
+	return code == http.StatusInternalServerError || code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// gatewayTimeout 构造 only-if-cached 请求没有可用缓存时返回的 504 响应
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 Gateway Timeout",
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{CacheHeader: {CacheMiss}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// cachingBody 在读取响应体的同时缓存内容，读取完毕后保存，超过大小上限时放弃缓存
+type cachingBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int64
+	done  func(body []byte)
+	skip  bool
+}
+
+func (this *cachingBody) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	if !this.skip {
+		this.buf.Write(p[:n])
+		if this.limit > 0 && int64(this.buf.Len()) > this.limit {
+			this.skip = true
+			this.buf = bytes.Buffer{}
+		}
+		if err == io.EOF {
+			this.skip = true
+			this.done(this.buf.Bytes())
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,146 @@
+package httpc
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore 定义 HTTP 缓存的存储接口，value 为序列化后的响应
+// 实现需要保证并发安全
+type CacheStore interface {
+	// Get 返回 key 对应的缓存，不存在时返回 false
+	Get(key string) ([]byte, bool)
+	// Set 保存 key 对应的缓存
+	Set(key string, value []byte)
+	// Delete 删除 key 对应的缓存
+	Delete(key string)
+}
+
+// memoryItem 为内存缓存中的一项
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+// MemoryCacheStore 基于内存的 LRU 缓存存储，总大小超过上限时淘汰最久未使用的缓存
+type MemoryCacheStore struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// NewMemoryCacheStore 创建内存缓存存储，maxBytes 为缓存的总字节数上限，小于等于 0 时不限制
+func NewMemoryCacheStore(maxBytes int64) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 返回 key 对应的缓存，并将其标记为最近使用
+func (this *MemoryCacheStore) Get(key string) ([]byte, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	e, ok := this.items[key]
+	if !ok {
+		return nil, false
+	}
+	this.ll.MoveToFront(e)
+	return e.Value.(*memoryItem).value, true
+}
+
+// Set 保存 key 对应的缓存，单个缓存超过上限时不保存
+func (this *MemoryCacheStore) Set(key string, value []byte) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.remove(key)
+	if this.maxBytes > 0 && int64(len(value)) > this.maxBytes {
+		return
+	}
+	this.items[key] = this.ll.PushFront(&memoryItem{key: key, value: value})
+	this.size += int64(len(value))
+	for this.maxBytes > 0 && this.size > this.maxBytes {
+		this.remove(this.ll.Back().Value.(*memoryItem).key)
+	}
+}
+
+// Delete 删除 key 对应的缓存
+func (this *MemoryCacheStore) Delete(key string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.remove(key)
+}
+
+// Len 返回缓存的数量
+func (this *MemoryCacheStore) Len() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.ll.Len()
+}
+
+// remove 删除缓存，调用者需持有锁
+func (this *MemoryCacheStore) remove(key string) {
+	if e, ok := this.items[key]; ok {
+		this.size -= int64(len(e.Value.(*memoryItem).value))
+		this.ll.Remove(e)
+		delete(this.items, key)
+	}
+}
+
+// DiskCacheStore 基于磁盘的缓存存储，每个缓存保存为目录下的一个文件，文件名为 key 的 sha256
+type DiskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore 创建磁盘缓存存储，dir 不存在时自动创建
+func NewDiskCacheStore(dir string) (*DiskCacheStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCacheStore{dir: dir}, nil
+}
+
+// Get 返回 key 对应的缓存
+func (this *DiskCacheStore) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(this.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set 保存 key 对应的缓存，先写入临时文件再重命名，写入失败时忽略
+func (this *DiskCacheStore) Set(key string, value []byte) {
+	file, err := os.CreateTemp(this.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), this.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+}
+
+// Delete 删除 key 对应的缓存
+func (this *DiskCacheStore) Delete(key string) {
+	_ = os.Remove(this.path(key))
+}
+
+// path 返回 key 对应的文件路径
+func (this *DiskCacheStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(this.dir, hex.EncodeToString(sum[:]))
+}
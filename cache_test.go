@@ -0,0 +1,313 @@
+package httpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// cacheServer 返回统计请求次数的测试服务
+func cacheServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	hits := new(atomic.Int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, hits
+}
+
+// cacheGet 发送请求并返回缓存状态与响应体
+func cacheGet(t *testing.T, req *Request) (string, string) {
+	t.Helper()
+	resp, data, err := req.Send().EndByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Header.Get(CacheHeader), string(data)
+}
+
+func TestCacheHit(t *testing.T) {
+	srv, hits := cacheServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("v1"))
+	})
+	client := NewHttpClient().SetCache(NewHttpCache(nil))
+	for i, want := range []string{CacheMiss, CacheHit, CacheHit} {
+		status, data := cacheGet(t, NewRequest(client).SetUrl(srv.URL))
+		if status != want || data != "v1" {
+			t.Errorf("request %d: got %s %q, want %s", i, status, data, want)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server received %d requests, want 1", n)
+	}
+}
+
+func TestCacheRevalidated(t *testing.T) {
+	srv, hits := cacheServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("X-Version", "2")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("X-Version", "1")
+		_, _ = w.Write([]byte("body"))
+	})
+	client := NewHttpClient().SetCache(NewHttpCache(nil))
+	if status, _ := cacheGet(t, NewRequest(client).SetUrl(srv.URL)); status != CacheMiss {
+		t.Fatalf("first request %s", status)
+	}
+	resp, data, err := NewRequest(client).SetUrl(srv.URL).Send().EndByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get(CacheHeader); got != CacheRevalidated || string(data) != "body" {
+		t.Errorf("got %s %q", got, data)
+	}
+	if got := resp.Header.Get("X-Version"); got != "2" {
+		t.Errorf("304 headers not merged, X-Version %q", got)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d", resp.StatusCode)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	tests := []struct {
+		cc   string
+		want string
+	}{
+		{"max-age=0, stale-if-error=60", CacheStale},
+		{"max-age=0, stale-if-error=60, must-revalidate", ""},
+		{"max-age=0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cc, func(t *testing.T) {
+			var fail atomic.Bool
+			srv, _ := cacheServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if fail.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Cache-Control", tt.cc)
+				_, _ = w.Write([]byte("cached"))
+			})
+			client := NewHttpClient().SetCache(NewHttpCache(nil))
+			cacheGet(t, NewRequest(client).SetUrl(srv.URL))
+			fail.Store(true)
+			resp, data, err := NewRequest(client).SetUrl(srv.URL).Send().EndByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == CacheStale {
+				if resp.Header.Get(CacheHeader) != CacheStale || string(data) != "cached" {
+					t.Errorf("got %s %q", resp.Header.Get(CacheHeader), data)
+				}
+			} else if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("got status %d, want 503", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	srv, hits := cacheServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+	})
+	client := NewHttpClient().SetCache(NewHttpCache(nil))
+	tests := []struct {
+		lang   string
+		status string
+	}{
+		{"en", CacheMiss},
+		{"en", CacheHit},
+		{"fr", CacheMiss},
+	}
+	for _, tt := range tests {
+		status, data := cacheGet(t, NewRequest(client).SetUrl(srv.URL).SetHeader("Accept-Language", tt.lang))
+		if status != tt.status || data != tt.lang {
+			t.Errorf("%s: got %s %q, want %s", tt.lang, status, data, tt.status)
+		}
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		method string
+		code   int
+		want   string
+	}{
+		{"POST", http.StatusOK, CacheMiss},
+		{"DELETE", http.StatusNoContent, CacheMiss},
+		{"POST", http.StatusBadRequest, CacheHit},
+		{"HEAD", http.StatusOK, CacheHit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			srv, _ := cacheServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					w.WriteHeader(tt.code)
+					return
+				}
+				w.Header().Set("Cache-Control", "max-age=60")
+				_, _ = w.Write([]byte("v1"))
+			})
+			client := NewHttpClient().SetCache(NewHttpCache(nil))
+			cacheGet(t, NewRequest(client).SetUrl(srv.URL))
+			if _, _, err := NewRequest(client).SetMethod(tt.method).SetUrl(srv.URL).Send().End(); err != nil {
+				t.Fatal(err)
+			}
+			if status, _ := cacheGet(t, NewRequest(client).SetUrl(srv.URL)); status != tt.want {
+				t.Errorf("after %s %d: got %s, want %s", tt.method, tt.code, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheRequestDirectives(t *testing.T) {
+	tests := []struct {
+		name string
+		resp string
+		age  string
+		req  string
+		want string
+	}{
+		{"fresh", "max-age=60", "", "", CacheHit},
+		{"stale", "max-age=10", "20", "", CacheMiss},
+		{"max-stale within", "max-age=10", "20", "max-stale=30", CacheHit},
+		{"max-stale exceeded", "max-age=10", "20", "max-stale=5", CacheMiss},
+		{"max-stale unbounded", "max-age=10", "20", "max-stale", CacheHit},
+		{"max-stale must-revalidate", "max-age=10, must-revalidate", "20", "max-stale", CacheMiss},
+		{"min-fresh", "max-age=60", "", "min-fresh=120", CacheMiss},
+		{"max-age", "max-age=60", "20", "max-age=10", CacheMiss},
+		{"no-cache", "max-age=60", "", "no-cache", CacheMiss},
+		{"no-store", "max-age=60", "", "no-store", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := cacheServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", tt.resp)
+				if tt.age != "" {
+					w.Header().Set("Age", tt.age)
+				}
+				_, _ = w.Write([]byte("v1"))
+			})
+			client := NewHttpClient().SetCache(NewHttpCache(nil))
+			cacheGet(t, NewRequest(client).SetUrl(srv.URL))
+			req := NewRequest(client).SetUrl(srv.URL)
+			if tt.req != "" {
+				req.SetHeader("Cache-Control", tt.req)
+			}
+			if status, _ := cacheGet(t, req); status != tt.want {
+				t.Errorf("got %q, want %q", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheOnlyIfCached(t *testing.T) {
+	srv, hits := cacheServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("v1"))
+	})
+	client := NewHttpClient().SetCache(NewHttpCache(nil))
+	resp, _, err := NewRequest(client).SetUrl(srv.URL).SetHeader("Cache-Control", "only-if-cached").Send().End()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusGatewayTimeout || hits.Load() != 0 {
+		t.Errorf("got status %d with %d server requests", resp.StatusCode, hits.Load())
+	}
+}
+
+func TestCacheSessionIsolation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", r.URL.Query().Get("cc"))
+		_, _ = w.Write([]byte("user=" + r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		cc   string
+		want string
+	}{
+		{"private, max-age=60", CacheMiss},
+		{"public, max-age=60", CacheHit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cc, func(t *testing.T) {
+			client := NewHttpClient().SetCache(NewHttpCache(nil))
+			alice := NewSession(client).SetBearerToken("alice")
+			bob := NewSession(client).SetBearerToken("bob")
+			for _, s := range []*Session{alice, alice, bob} {
+				if _, _, err := s.NewRequest().SetUrl(srv.URL).SetParam("cc", tt.cc).Send().EndByte(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp, data, err := alice.NewRequest().SetUrl(srv.URL).SetParam("cc", tt.cc).Send().EndByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Header.Get(CacheHeader); got != tt.want {
+				t.Errorf("alice cache status %s, want %s", got, tt.want)
+			}
+			if string(data) != "user=Bearer alice" {
+				t.Errorf("alice got %q", data)
+			}
+			_, data, err = bob.NewRequest().SetUrl(srv.URL).SetParam("cc", tt.cc).Send().EndByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "user=Bearer bob" {
+				t.Errorf("bob got %q", data)
+			}
+		})
+	}
+}
+
+func TestCacheDoesNotReplaySetCookie(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			// Cookie 作用于其它路径，不会改变 /page 请求的 Cookie 与缓存键
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "old", Path: "/app"})
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/app/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "new", Path: "/app"})
+			w.Header().Set("Cache-Control", "no-store")
+		case "/app/me":
+			w.Header().Set("Cache-Control", "no-store")
+			_, _ = w.Write([]byte(r.Header.Get("Cookie")))
+		}
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient().SetCache(NewHttpCache(nil)).SetCookieJar(NewCookieJar())
+	var resp *http.Response
+	var data []byte
+	var err error
+	for _, path := range []string{"/page", "/app/login", "/page", "/app/me"} {
+		if resp, data, err = NewRequest(client).SetUrl(srv.URL + path).Send().EndByte(); err != nil {
+			t.Fatal(err)
+		}
+		if path == "/page" && resp.Header.Get("Set-Cookie") != "" && resp.Header.Get(CacheHeader) == CacheHit {
+			t.Error("cached response carries Set-Cookie")
+		}
+	}
+	if string(data) != "sid=new" {
+		t.Errorf("cookie rolled back: %q", data)
+	}
+}
@@ -0,0 +1,136 @@
+package httpc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// charsetSniffLen 为检测 HTML/XML 声明时读取的最大字节数
+const charsetSniffLen = 1024
+
+var (
+	metaCharsetRegexp = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.\-]+)`)
+	xmlCharsetRegexp  = regexp.MustCompile(`(?i)<\?xml[^>]+encoding\s*=\s*["']([a-z0-9_:.\-]+)`)
+)
+
+// detectCharset 检测响应体的字符集，检测不到时返回空字符串
+// 依次检查 BOM、Content-Type 中的 charset 以及 HTML <meta charset> 或 XML 声明中的编码
+func detectCharset(contentType string, body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+
+	mediaType := ""
+	if contentType != "" {
+		var params map[string]string
+		mediaType, params, _ = mime.ParseMediaType(contentType)
+		if charset := params["charset"]; charset != "" {
+			return strings.ToLower(charset)
+		}
+	}
+	if !isTextMediaType(mediaType) {
+		return ""
+	}
+
+	head := body
+	if len(head) > charsetSniffLen {
+		head = head[:charsetSniffLen]
+	}
+	if m := metaCharsetRegexp.FindSubmatch(head); m != nil {
+		return strings.ToLower(string(m[1]))
+	}
+	if m := xmlCharsetRegexp.FindSubmatch(head); m != nil {
+		return strings.ToLower(string(m[1]))
+	}
+	return ""
+}
+
+// isTextMediaType 判断媒体类型是否为文本类型，为空时视为文本
+func isTextMediaType(mediaType string) bool {
+	return mediaType == "" || strings.HasPrefix(mediaType, "text/") ||
+		strings.Contains(mediaType, "html") || strings.Contains(mediaType, "xml") ||
+		strings.Contains(mediaType, "json") || strings.Contains(mediaType, "javascript")
+}
+
+// lookupCharset 根据名称查找字符集编码，名称遵循 WHATWG 编码标准，例如 gbk、gb2312、big5、shift_jis
+func lookupCharset(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, errors.New("unsupported charset: " + name)
+	}
+	return enc, nil
+}
+
+// toUTF8 将指定字符集的内容转换为 UTF-8，并去除开头的 BOM
+// charset 为空或为 UTF-8 时仅去除 BOM
+func toUTF8(body []byte, charset string) ([]byte, error) {
+	if charset == "" {
+		return bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF}), nil
+	}
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := htmlindex.Name(enc)
+	switch name {
+	case "utf-8":
+		return bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF}), nil
+	case "utf-16be":
+		body = bytes.TrimPrefix(body, []byte{0xFE, 0xFF})
+	case "utf-16le":
+		body = bytes.TrimPrefix(body, []byte{0xFF, 0xFE})
+	}
+	return enc.NewDecoder().Bytes(body)
+}
+
+// xmlCharsetReader 供 xml.Decoder 使用，将 XML 声明中的编码转换为 UTF-8
+func xmlCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// SetResponseCharset 指定响应体的字符集，例如 "gbk"、"big5"
+// End 与 Response.Text 会按该字符集将响应体转换为 UTF-8；为空时自动检测
+func (this *Request) SetResponseCharset(charset string) *Request {
+	this.charset = strings.ToLower(strings.TrimSpace(charset))
+	return this
+}
+
+// SetAutoCharset 设置是否对响应体进行字符集检测与转换，默认开启
+// 关闭后 End 与 Response.Text 直接返回原始内容
+func (this *Request) SetAutoCharset(auto bool) *Request {
+	this.rawCharset = !auto
+	return this
+}
+
+// decodeText 按照请求的字符集设置将响应体转换为 UTF-8
+// 自动检测到的字符集不受支持时返回原始内容，手动指定的字符集不受支持时返回错误
+func (this *Request) decodeText(resp *http.Response, body []byte) ([]byte, error) {
+	if this.rawCharset {
+		return body, nil
+	}
+	if this.charset != "" {
+		return toUTF8(body, this.charset)
+	}
+	text, err := toUTF8(body, detectCharset(resp.Header.Get("Content-Type"), body))
+	if err != nil {
+		return body, nil
+	}
+	return text, nil
+}
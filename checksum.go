@@ -0,0 +1,179 @@
+package httpc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ChecksumError 表示下载文件的摘要与期望值不一致
+// 发生该错误时已下载的文件会被删除
+type ChecksumError struct {
+	// Algorithm 摘要算法，例如 "sha256"
+	Algorithm string
+	// Expected 期望的摘要，十六进制小写
+	Expected string
+	// Actual 实际计算得到的摘要，十六进制小写
+	Actual string
+}
+
+func (this *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch: %s expected %s, got %s", this.Algorithm, this.Expected, this.Actual)
+}
+
+// SetChecksum 设置下载文件的期望摘要，EndFile 与 Download 会在写入时同步计算并校验
+// algorithm 支持 "sha256"、"sha1"、"md5"（不区分大小写，可写作 "SHA-256"），expected 为十六进制或 Base64 编码的摘要
+func (this *Request) SetChecksum(algorithm, expected string) *Request {
+	this.checksumAlgorithm = algorithm
+	this.checksumExpected = expected
+	return this
+}
+
+// SetChecksumFromHeader 设置是否在未指定期望摘要时使用响应头中的摘要进行校验
+// 支持 Digest、Repr-Digest、Content-MD5 与 x-goog-hash，存在多种摘要时优先使用 sha256
+// 响应经过 Content-Encoding 压缩时无法确定摘要对应的内容，不会进行校验
+func (this *Request) SetChecksumFromHeader(b bool) *Request {
+	this.checksumFromHeader = b
+	return this
+}
+
+// checksumVerifier 在写入文件的同时计算摘要
+type checksumVerifier struct {
+	algorithm string
+	expected  []byte
+	hash      hash.Hash
+}
+
+// verify 比较计算得到的摘要与期望值
+func (this *checksumVerifier) verify() error {
+	actual := this.hash.Sum(nil)
+	if bytes.Equal(actual, this.expected) {
+		return nil
+	}
+	return &ChecksumError{
+		Algorithm: this.algorithm,
+		Expected:  hex.EncodeToString(this.expected),
+		Actual:    hex.EncodeToString(actual),
+	}
+}
+
+// newChecksumVerifier 根据请求设置与响应头创建摘要校验器，不需要校验时返回 nil
+// partPath 不为空时表示续传，已下载部分的内容会先计入摘要
+func (this *Request) newChecksumVerifier(resp *http.Response, partPath string) (*checksumVerifier, error) {
+	var verifier *checksumVerifier
+	if this.checksumExpected != "" {
+		algorithm := normalizeAlgorithm(this.checksumAlgorithm)
+		h := newHash(algorithm)
+		if h == nil {
+			return nil, errors.New("unsupported checksum algorithm: " + this.checksumAlgorithm)
+		}
+		expected, ok := decodeDigest(this.checksumExpected, h.Size())
+		if !ok {
+			return nil, errors.New("invalid checksum: " + this.checksumExpected)
+		}
+		verifier = &checksumVerifier{algorithm: algorithm, expected: expected, hash: h}
+	} else if this.checksumFromHeader {
+		verifier = headerChecksum(resp, partPath != "")
+	}
+	if verifier == nil || partPath == "" {
+		return verifier, nil
+	}
+
+	file, err := os.Open(partPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err = io.Copy(verifier.hash, file); err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// headerChecksum 从响应头中获取摘要，partial 为 true 时忽略只对应部分内容的 Content-MD5
+func headerChecksum(resp *http.Response, partial bool) *checksumVerifier {
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if resp.Uncompressed || (encoding != "" && encoding != "identity") {
+		return nil
+	}
+
+	digests := make(map[string]string)
+	for _, name := range []string{"Digest", "Repr-Digest", "X-Goog-Hash"} {
+		for _, value := range resp.Header.Values(name) {
+			for _, item := range strings.Split(value, ",") {
+				k, v, ok := strings.Cut(strings.TrimSpace(item), "=")
+				if !ok {
+					continue
+				}
+				algorithm := normalizeAlgorithm(k)
+				if _, exists := digests[algorithm]; !exists {
+					digests[algorithm] = strings.Trim(strings.TrimSpace(v), ":")
+				}
+			}
+		}
+	}
+	if md5Value := resp.Header.Get("Content-MD5"); md5Value != "" && !partial {
+		if _, exists := digests["md5"]; !exists {
+			digests["md5"] = md5Value
+		}
+	}
+
+	for _, algorithm := range []string{"sha256", "sha1", "md5"} {
+		value, ok := digests[algorithm]
+		if !ok {
+			continue
+		}
+		h := newHash(algorithm)
+		if expected, ok := decodeDigest(value, h.Size()); ok {
+			return &checksumVerifier{algorithm: algorithm, expected: expected, hash: h}
+		}
+	}
+	return nil
+}
+
+// normalizeAlgorithm 将 "SHA-256"、"sha" 等写法统一为 "sha256"、"sha1"、"md5"
+func normalizeAlgorithm(algorithm string) string {
+	algorithm = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(algorithm), "-", ""))
+	if algorithm == "sha" {
+		return "sha1"
+	}
+	return algorithm
+}
+
+// newHash 根据算法名称创建 hash.Hash，不支持时返回 nil
+func newHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "sha256":
+		return sha256.New()
+	case "sha1":
+		return sha1.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+}
+
+// decodeDigest 解码十六进制或 Base64 编码的摘要，并校验长度
+func decodeDigest(value string, size int) ([]byte, bool) {
+	value = strings.TrimSpace(value)
+	if b, err := hex.DecodeString(value); err == nil && len(b) == size {
+		return b, true
+	}
+	if b, err := base64.StdEncoding.DecodeString(value); err == nil && len(b) == size {
+		return b, true
+	}
+	if b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "=")); err == nil && len(b) == size {
+		return b, true
+	}
+	return nil, false
+}
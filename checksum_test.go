@@ -0,0 +1,198 @@
+package httpc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	checksumContent = []byte(strings.Repeat("httpc checksum ", 100))
+	checksumSha256  = sha256.Sum256(checksumContent)
+	checksumSha1    = sha1.Sum(checksumContent)
+	checksumMd5     = md5.Sum(checksumContent)
+)
+
+func TestDecodeDigest(t *testing.T) {
+	sum := checksumSha256[:]
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"hex", hex.EncodeToString(sum), true},
+		{"upper hex", strings.ToUpper(hex.EncodeToString(sum)), true},
+		{"base64", base64.StdEncoding.EncodeToString(sum), true},
+		{"raw url base64", base64.RawURLEncoding.EncodeToString(sum), true},
+		{"padded url base64", base64.URLEncoding.EncodeToString(sum), true},
+		{"surrounding space", " " + hex.EncodeToString(sum) + " ", true},
+		{"wrong length", hex.EncodeToString(sum[:20]), false},
+		{"garbage", "not a digest", false},
+	}
+	for _, tt := range tests {
+		got, ok := decodeDigest(tt.value, sha256.Size)
+		if ok != tt.ok || (ok && !bytes.Equal(got, sum)) {
+			t.Errorf("%s: decodeDigest(%q) = %x, %v", tt.name, tt.value, got, ok)
+		}
+	}
+}
+
+func TestHeaderChecksum(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	tests := []struct {
+		name      string
+		header    http.Header
+		partial   bool
+		algorithm string
+		expected  []byte
+	}{
+		{"digest", http.Header{"Digest": {"SHA-256=" + b64(checksumSha256[:])}}, false, "sha256", checksumSha256[:]},
+		{"repr digest", http.Header{"Repr-Digest": {"sha-256=:" + b64(checksumSha256[:]) + ":"}}, false, "sha256", checksumSha256[:]},
+		{"digest sha", http.Header{"Digest": {"SHA=" + b64(checksumSha1[:])}}, false, "sha1", checksumSha1[:]},
+		{"goog hash", http.Header{"X-Goog-Hash": {"crc32c=n03x6A==,md5=" + b64(checksumMd5[:])}}, false, "md5", checksumMd5[:]},
+		{"content md5", http.Header{"Content-Md5": {b64(checksumMd5[:])}}, false, "md5", checksumMd5[:]},
+		{"content md5 partial", http.Header{"Content-Md5": {b64(checksumMd5[:])}}, true, "", nil},
+		{"prefer sha256", http.Header{
+			"Digest":      {"md5=" + b64(checksumMd5[:]) + ", sha-256=" + b64(checksumSha256[:])},
+			"Content-Md5": {b64(checksumMd5[:])},
+		}, false, "sha256", checksumSha256[:]},
+		{"skip invalid", http.Header{"Digest": {"sha-256=bad, md5=" + b64(checksumMd5[:])}}, false, "md5", checksumMd5[:]},
+		{"compressed", http.Header{"Digest": {"sha-256=" + b64(checksumSha256[:])}, "Content-Encoding": {"gzip"}}, false, "", nil},
+		{"unsupported", http.Header{"Digest": {"sha-512=" + b64(checksumSha256[:])}}, false, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := headerChecksum(&http.Response{Header: tt.header}, tt.partial)
+			if tt.algorithm == "" {
+				if v != nil {
+					t.Errorf("got %s verifier, want none", v.algorithm)
+				}
+				return
+			}
+			if v == nil || v.algorithm != tt.algorithm || !bytes.Equal(v.expected, tt.expected) {
+				t.Errorf("got %+v, want %s %x", v, tt.algorithm, tt.expected)
+			}
+		})
+	}
+}
+
+func TestChecksumMismatchRemovesFile(t *testing.T) {
+	wrong := sha256.Sum256([]byte("other"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(wrong[:]))
+		_, _ = w.Write(checksumContent)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		save func(req *Request, dir string) error
+	}{
+		{"EndFile", func(req *Request, dir string) error {
+			_, err := req.SetChecksum("SHA-256", hex.EncodeToString(wrong[:])).Send().EndFile(dir, "file")
+			return err
+		}},
+		{"Download", func(req *Request, dir string) error {
+			_, err := req.SetChecksum("SHA-256", hex.EncodeToString(wrong[:])).Download(dir, "file")
+			return err
+		}},
+		{"header digest", func(req *Request, dir string) error {
+			_, err := req.SetChecksumFromHeader(true).Download(dir, "file")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := tt.save(NewRequest(NewHttpClient()).SetUrl(srv.URL), dir)
+			var checksumErr *ChecksumError
+			if !errors.As(err, &checksumErr) {
+				t.Fatalf("got %v, want *ChecksumError", err)
+			}
+			if checksumErr.Algorithm != "sha256" || checksumErr.Expected != hex.EncodeToString(wrong[:]) ||
+				checksumErr.Actual != hex.EncodeToString(checksumSha256[:]) {
+				t.Errorf("error %+v", checksumErr)
+			}
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				t.Errorf("%s left behind", e.Name())
+			}
+		})
+	}
+}
+
+func TestChecksumResumedDownload(t *testing.T) {
+	half := len(checksumContent) / 2
+	tests := []struct {
+		name    string
+		part    []byte
+		wantErr bool
+	}{
+		{"intact part", checksumContent[:half], false},
+		// 已下载部分被破坏时，续传后重新计算的摘要与期望值不一致
+		{"corrupted part", bytes.Repeat([]byte("x"), half), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranged atomic.Bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "" {
+					ranged.Store(true)
+				}
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(checksumContent))
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "file")
+			if err := os.WriteFile(dest+partSuffix, tt.part, 0644); err != nil {
+				t.Fatal(err)
+			}
+			savePartialMeta(dest, srv.URL, &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Etag": {`"v1"`}},
+				ContentLength: int64(len(checksumContent)),
+			})
+
+			resp, err := NewRequest(NewHttpClient()).SetUrl(srv.URL).
+				SetChecksum("sha256", hex.EncodeToString(checksumSha256[:])).Download(dir, "file")
+			if !ranged.Load() || resp == nil || resp.StatusCode != http.StatusPartialContent {
+				t.Fatalf("download was not resumed: %v", err)
+			}
+			if tt.wantErr {
+				var checksumErr *ChecksumError
+				if !errors.As(err, &checksumErr) {
+					t.Fatalf("got %v, want *ChecksumError", err)
+				}
+				if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+					t.Errorf("%d files left behind", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, checksumContent) {
+				t.Error("resumed file content mismatch")
+			}
+		})
+	}
+}
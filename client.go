@@ -10,8 +10,18 @@ import (
 // HttpClient 封装了 http.Client 与 http.Transport
 // 是构建 Request 的基础客户端对象
 type HttpClient struct {
-	client    *http.Client
-	transport *http.Transport
+	client      *http.Client
+	transport   *http.Transport
+	retry       *RetryPolicy
+	middlewares []Middleware
+	decoders    map[string]Decoder
+	limiter     *rateLimiter
+	breaker     *CircuitBreaker
+	cache       *HttpCache
+	baseUrl     string
+	headerOrder bool
+	headerCase  bool
+	rawPool     *rawPool
 }
 
 // NewHttpClient 创建并返回一个默认配置的 HttpClient 实例
@@ -21,6 +31,7 @@ func NewHttpClient() *HttpClient {
 		MaxIdleConnsPerHost:   50,
 		MaxConnsPerHost:       100,
 		ResponseHeaderTimeout: 15 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
 		TLSClientConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
 		},
@@ -29,7 +40,7 @@ func NewHttpClient() *HttpClient {
 		Transport: defaultTransport,
 		Timeout:   30 * time.Second,
 	}
-	return &HttpClient{client: client, transport: defaultTransport}
+	return &HttpClient{client: client, transport: defaultTransport, decoders: defaultDecoders(), rawPool: newRawPool()}
 }
 
 // CustomizeTransport 允许自定义底层 http.Transport 的所有字段
@@ -80,3 +91,52 @@ func (this *HttpClient) SetRedirect(f func(req *http.Request, via []*http.Reques
 	this.client.CheckRedirect = f
 	return this
 }
+
+// SetBaseUrl 设置客户端的基础 URL，例如 "https://api.example.com/v1"
+// 由该客户端创建的请求通过 SetUrl 设置相对地址时，会拼接在基础 URL 之后并保留基础 URL 的路径，
+// 设置带协议的绝对地址时不受影响
+func (this *HttpClient) SetBaseUrl(baseUrl string) *HttpClient {
+	this.baseUrl = baseUrl
+	return this
+}
+
+// SetRetry 设置客户端默认的重试策略，传入 nil 表示不重试
+// 由该客户端创建的请求若未单独设置重试策略，将使用此策略
+func (this *HttpClient) SetRetry(p *RetryPolicy) *HttpClient {
+	this.retry = p
+	return this
+}
+
+// Use 为客户端注册中间件，按注册顺序由外到内依次执行
+// 中间件作用于该客户端发出的所有请求，可用于鉴权注入、日志、监控与签名等场景
+func (this *HttpClient) Use(middlewares ...Middleware) *HttpClient {
+	this.middlewares = append(this.middlewares, middlewares...)
+	return this
+}
+
+// do 通过中间件链发送请求
+// 参数 middlewares 为请求级中间件，位于客户端中间件之内
+func (this *HttpClient) do(req *http.Request, middlewares []Middleware) (*http.Response, error) {
+	if len(this.middlewares) == 0 && len(middlewares) == 0 && this.limiter == nil && this.breaker == nil && this.cache == nil && !this.headerOrder && !this.headerCase {
+		return this.client.Do(req)
+	}
+	client := *this.client
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if this.headerOrder || this.headerCase {
+		transport = &rawTransport{transport: this.transport, pool: this.rawPool, preserveCase: this.headerCase}
+	}
+	if this.limiter != nil {
+		transport = this.limiter.wrap(transport)
+	}
+	if this.breaker != nil {
+		transport = this.breaker.wrap(transport)
+	}
+	if this.cache != nil {
+		transport = this.cache.wrap(transport)
+	}
+	client.Transport = chainMiddleware(transport, this.middlewares, middlewares)
+	return client.Do(req)
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 )
@@ -19,16 +20,58 @@ type CookieJar struct {
 	entries map[string]map[string]entry
 
 	nextSeqNum uint64
+
+	filename    string
+	keepSession bool
+	// saved 为最近一次准备写回的 Cookie，pending 为等待写入文件的内容
+	saved   []jarRecord
+	pending []byte
+	dirty   atomic.Bool
+	saveErr error
+	// fileMu 保证同一时间只有一个 goroutine 写入文件
+	fileMu sync.Mutex
+
+	psList PublicSuffixList
+
+	strictPath bool
 }
 
 func NewCookieJar() *CookieJar {
 	jar := &CookieJar{
 		entries: make(map[string]map[string]entry),
+		psList:  DefaultPublicSuffixList(),
 	}
 
 	return jar
 }
 
+// SetStrictPath 设置是否按照 RFC 6265 计算 Cookie 的默认 Path
+// 开启后未指定 Path 的 Cookie 仅作用于设置它的请求路径所在目录，例如 /app/login 设置的 Cookie 作用于 /app
+// 默认关闭，此时未指定 Path 的 Cookie 统一作用于 /
+func (j *CookieJar) SetStrictPath(strict bool) *CookieJar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.strictPath = strict
+	return j
+}
+
+// SetPublicSuffixList 设置 CookieJar 使用的公共后缀列表，默认使用 golang.org/x/net/publicsuffix.List
+// 传入 nil 时退化为以域名最后两段作为可注册域名，且不再拒绝为公共后缀设置的 Cookie
+func (j *CookieJar) SetPublicSuffixList(list PublicSuffixList) *CookieJar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.psList = list
+	old := j.entries
+	j.entries = make(map[string]map[string]entry)
+	for _, submap := range old {
+		for _, e := range submap {
+			j.insert(e)
+		}
+	}
+	return j
+}
+
 type entry struct {
 	Name       string
 	Value      string
@@ -94,11 +137,11 @@ func (j *CookieJar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie)
 		return cookies
 	}
 
-	key := jarKey(host)
-
+	defer j.writeBack()
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
+	key := jarKey(host, j.psList)
 	submap := j.entries[key]
 	if submap == nil {
 		return cookies
@@ -110,12 +153,12 @@ func (j *CookieJar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie)
 		path = "/"
 	}
 
-	modified := false
+	modified, removed := false, false
 	var selected []entry
 	for id, e := range submap {
 		if e.Persistent && !e.Expires.After(now) {
 			delete(submap, id)
-			modified = true
+			modified, removed = true, true
 			continue
 		}
 		if !e.shouldSend(https, host, path) {
@@ -134,6 +177,9 @@ func (j *CookieJar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie)
 			j.entries[key] = submap
 		}
 	}
+	if removed {
+		j.autoSave()
+	}
 
 	sort.Slice(selected, func(i, j int) bool {
 		s := selected
@@ -165,18 +211,19 @@ func (j *CookieJar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time
 	if u.Scheme != "http" && u.Scheme != "https" {
 		return
 	}
-	//There may be errors in setting local domain names, such as:.com.cm
 	host, err := canonicalHost(u.Host)
 	if err != nil {
 		return
 	}
 
-	key := jarKey(host)
-	defPath := defaultPath(u.Path)
-
+	defer j.writeBack()
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
+	defPath := defaultPath(u.Path, j.strictPath)
+
+	key := jarKey(host, j.psList)
+
 	submap := j.entries[key]
 
 	modified := false
@@ -218,6 +265,7 @@ func (j *CookieJar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time
 		} else {
 			j.entries[key] = submap
 		}
+		j.autoSave()
 	}
 }
 
@@ -250,14 +298,26 @@ func hasPort(host string) bool {
 	return host[0] == '[' && strings.Contains(host, "]:")
 }
 
-func jarKey(host string) string {
+func jarKey(host string, psl PublicSuffixList) string {
 	if isIP(host) {
 		return host
 	}
 
-	i := strings.LastIndex(host, ".")
-	if i <= 0 {
-		return host
+	var i int
+	if psl == nil {
+		i = strings.LastIndex(host, ".")
+		if i <= 0 {
+			return host
+		}
+	} else {
+		suffix := psl.PublicSuffix(host)
+		if suffix == host {
+			return host
+		}
+		i = len(host) - len(suffix)
+		if i <= 0 || host[i-1] != '.' {
+			return host
+		}
 	}
 
 	prevDot := strings.LastIndex(host[:i-1], ".")
@@ -268,8 +328,13 @@ func isIP(host string) bool {
 	return net.ParseIP(host) != nil
 }
 
-func defaultPath(path string) string {
-	/*if len(path) == 0 || path[0] != '/' {
+func defaultPath(path string, strict bool) string {
+	//修复了设置cookie时path作用域的问题，非严格模式下统一设置/
+	if !strict {
+		return "/"
+	}
+
+	if len(path) == 0 || path[0] != '/' {
 		return "/"
 	}
 
@@ -277,9 +342,7 @@ func defaultPath(path string) string {
 	if i == 0 {
 		return "/"
 	}
-	return path[:i]*/
-	//修复了设置cookie时path作用域的问题，统一设置/
-	return "/"
+	return path[:i]
 }
 
 func (j *CookieJar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e entry, remove bool, err error) {
@@ -325,6 +388,8 @@ func (j *CookieJar) newEntry(c *http.Cookie, now time.Time, defPath, host string
 		e.SameSite = "SameSite=Strict"
 	case http.SameSiteLaxMode:
 		e.SameSite = "SameSite=Lax"
+	case http.SameSiteNoneMode:
+		e.SameSite = "SameSite=None"
 	}
 
 	return e, false, nil
@@ -360,6 +425,15 @@ func (j *CookieJar) domainAndType(host, domain string) (string, bool, error) {
 		return "", false, errMalformedDomain
 	}
 
+	if j.psList != nil {
+		if ps := j.psList.PublicSuffix(domain); ps != "" && !hasDotSuffix(domain, ps) {
+			if host == domain {
+				return host, true, nil
+			}
+			return "", false, errIllegalDomain
+		}
+	}
+
 	if host != domain && !hasDotSuffix(host, domain) {
 		return "", false, errIllegalDomain
 	}
@@ -0,0 +1,326 @@
+package httpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// cookieJarVersion 为持久化文件的格式版本
+const cookieJarVersion = 1
+
+// jarFile 为 CookieJar 持久化时的 JSON 结构
+type jarFile struct {
+	Version int         `json:"version"`
+	Cookies []jarRecord `json:"cookies"`
+}
+
+// jarRecord 对应一个 entry 的全部字段
+type jarRecord struct {
+	Name       string    `json:"name"`
+	Value      string    `json:"value"`
+	Domain     string    `json:"domain"`
+	Path       string    `json:"path"`
+	SameSite   string    `json:"same_site,omitempty"`
+	Secure     bool      `json:"secure"`
+	HttpOnly   bool      `json:"http_only"`
+	Persistent bool      `json:"persistent"`
+	HostOnly   bool      `json:"host_only"`
+	Expires    time.Time `json:"expires"`
+	Creation   time.Time `json:"creation"`
+	LastAccess time.Time `json:"last_access"`
+	SeqNum     uint64    `json:"seq_num"`
+}
+
+// NewFileCookieJar 创建一个以文件为存储的 CookieJar
+// 若文件已存在则先从文件中加载 Cookie，之后每当需要保存的 Cookie 发生变化时自动写回文件
+func NewFileCookieJar(filename string) (*CookieJar, error) {
+	jar := NewCookieJar()
+	fd, err := os.Open(filename)
+	if err == nil {
+		err = jar.Load(fd)
+		_ = fd.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	jar.filename = filename
+	jar.saved = jar.records(jar.keepSession)
+	return jar, nil
+}
+
+// SetKeepSession 设置保存时是否包含会话 Cookie（未设置过期时间的 Cookie）
+// 默认不保存会话 Cookie
+func (j *CookieJar) SetKeepSession(keep bool) *CookieJar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keepSession = keep
+	return j
+}
+
+// Save 将 CookieJar 中的 Cookie 以 JSON 格式写入 w
+// 已过期的 Cookie 不会被写入，会话 Cookie 仅在开启 SetKeepSession 时写入
+func (j *CookieJar) Save(w io.Writer) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.save(w, j.keepSession)
+}
+
+// Load 从 r 中读取 JSON 格式的 Cookie 并合并到 CookieJar 中
+// 与已有 Cookie 的 Domain、Path、Name 相同时以读取到的为准，已过期的 Cookie 会被忽略
+func (j *CookieJar) Load(r io.Reader) error {
+	var file jarFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+	if file.Version != cookieJarVersion {
+		return errors.New("cookiejar: unsupported file version")
+	}
+
+	defer j.writeBack()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, r := range file.Cookies {
+		e := entry{
+			Name:       r.Name,
+			Value:      r.Value,
+			Domain:     r.Domain,
+			Path:       r.Path,
+			SameSite:   r.SameSite,
+			Secure:     r.Secure,
+			HttpOnly:   r.HttpOnly,
+			Persistent: r.Persistent,
+			HostOnly:   r.HostOnly,
+			Expires:    r.Expires,
+			Creation:   r.Creation,
+			LastAccess: r.LastAccess,
+			seqNum:     r.SeqNum,
+		}
+		if e.Persistent && !e.Expires.After(now) {
+			continue
+		}
+		j.insert(e)
+	}
+	j.autoSave()
+	return nil
+}
+
+// replace 以 src 中的全部 Cookie 替换当前的 Cookie
+func (j *CookieJar) replace(src *CookieJar) {
+	src.mu.Lock()
+	all := src.sortedEntries()
+	src.mu.Unlock()
+
+	defer j.writeBack()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = make(map[string]map[string]entry)
+	for _, e := range all {
+		j.insert(e)
+	}
+	j.autoSave()
+}
+
+// Flush 立即将 Cookie 写入 NewFileCookieJar 指定的文件
+// 自动写回发生的错误不会中断请求，本次写入成功时 Flush 返回上次 Flush 之后自动写回发生的最后一个错误
+func (j *CookieJar) Flush() error {
+	defer j.writeBack()
+	j.fileMu.Lock()
+	defer j.fileMu.Unlock()
+
+	j.mu.Lock()
+	if j.filename == "" {
+		j.mu.Unlock()
+		return errors.New("cookiejar: no file associated with jar")
+	}
+	records := j.records(j.keepSession)
+	var buf bytes.Buffer
+	err := encodeJarFile(&buf, records)
+	j.pending = nil
+	j.dirty.Store(false)
+	autoErr := j.saveErr
+	j.saveErr = nil
+	j.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomic(j.filename, buf.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.saved = records
+	j.mu.Unlock()
+	return autoErr
+}
+
+// insert 将 entry 直接放入对应的分组中，调用者需持有锁
+func (j *CookieJar) insert(e entry) {
+	key := jarKey(e.Domain, j.psList)
+	submap := j.entries[key]
+	if submap == nil {
+		submap = make(map[string]entry)
+		j.entries[key] = submap
+	}
+	if e.Path == "" {
+		e.Path = "/"
+	}
+	if e.Expires.IsZero() {
+		e.Expires = endOfTime
+	}
+	submap[e.id()] = e
+	if e.seqNum >= j.nextSeqNum {
+		j.nextSeqNum = e.seqNum + 1
+	}
+}
+
+// sortedEntries 返回按 Domain、Path 与创建顺序排序的全部 entry，调用者需持有锁
+func (j *CookieJar) sortedEntries() []entry {
+	var all []entry
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			all = append(all, e)
+		}
+	}
+	sort.Slice(all, func(a, b int) bool {
+		if all[a].Domain != all[b].Domain {
+			return all[a].Domain < all[b].Domain
+		}
+		if all[a].Path != all[b].Path {
+			return all[a].Path < all[b].Path
+		}
+		return all[a].seqNum < all[b].seqNum
+	})
+	return all
+}
+
+// records 返回需要保存的 Cookie，调用者需持有锁
+func (j *CookieJar) records(keepSession bool) []jarRecord {
+	now := time.Now()
+	records := []jarRecord{}
+	for _, e := range j.sortedEntries() {
+		if e.Persistent && !e.Expires.After(now) {
+			continue
+		}
+		if !e.Persistent && !keepSession {
+			continue
+		}
+		records = append(records, jarRecord{
+			Name:       e.Name,
+			Value:      e.Value,
+			Domain:     e.Domain,
+			Path:       e.Path,
+			SameSite:   e.SameSite,
+			Secure:     e.Secure,
+			HttpOnly:   e.HttpOnly,
+			Persistent: e.Persistent,
+			HostOnly:   e.HostOnly,
+			Expires:    e.Expires,
+			Creation:   e.Creation,
+			LastAccess: e.LastAccess,
+			SeqNum:     e.seqNum,
+		})
+	}
+	return records
+}
+
+// save 将 Cookie 以 JSON 格式写入 w，调用者需持有锁
+func (j *CookieJar) save(w io.Writer, keepSession bool) error {
+	return encodeJarFile(w, j.records(keepSession))
+}
+
+func encodeJarFile(w io.Writer, records []jarRecord) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jarFile{Version: cookieJarVersion, Cookies: records})
+}
+
+// sameRecords 判断两组 Cookie 除 LastAccess 外是否相同
+func sameRecords(a, b []jarRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.LastAccess, y.LastAccess = time.Time{}, time.Time{}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// autoSave 在设置了存储文件且需要保存的 Cookie 发生变化时准备写回的内容，调用者需持有锁
+// 仅有会话 Cookie 或 LastAccess 变化时不会写回，实际写入由 writeBack 在释放锁之后完成
+func (j *CookieJar) autoSave() {
+	if j.filename == "" {
+		return
+	}
+	records := j.records(j.keepSession)
+	if j.saved != nil && sameRecords(j.saved, records) {
+		return
+	}
+	var buf bytes.Buffer
+	if err := encodeJarFile(&buf, records); err != nil {
+		j.saveErr = err
+		return
+	}
+	j.saved = records
+	j.pending = buf.Bytes()
+	j.dirty.Store(true)
+}
+
+// writeBack 将 autoSave 准备的内容写入文件，调用者不能持有锁
+// 其他 goroutine 正在写入时直接返回，由正在写入的 goroutine 写入最新的内容
+func (j *CookieJar) writeBack() {
+	for j.dirty.Load() && j.fileMu.TryLock() {
+		for {
+			j.mu.Lock()
+			data := j.pending
+			j.pending = nil
+			j.dirty.Store(false)
+			j.mu.Unlock()
+			if data == nil {
+				break
+			}
+			err := writeFileAtomic(j.filename, data)
+			if err != nil {
+				j.mu.Lock()
+				j.saveErr = err
+				j.saved = nil
+				j.mu.Unlock()
+			}
+		}
+		j.fileMu.Unlock()
+	}
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免写入中断导致文件损坏
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package httpc
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// All 返回 CookieJar 中所有未过期的 Cookie，包含 Path、Expires、Secure、HttpOnly、SameSite 等完整属性
+// 会话 Cookie 的 Expires 为零值
+func (j *CookieJar) All() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, e := range j.sortedEntries() {
+		if e.Persistent && !e.Expires.After(now) {
+			continue
+		}
+		cookies = append(cookies, e.toCookie())
+	}
+	return cookies
+}
+
+// ForDomain 返回会被发送到 host 的所有未过期 Cookie，不区分 Path 与协议
+// 返回的 Cookie 包含完整属性
+func (j *CookieJar) ForDomain(host string) []*http.Cookie {
+	host, err := canonicalHost(host)
+	if err != nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, e := range j.sortedEntries() {
+		if e.Persistent && !e.Expires.After(now) {
+			continue
+		}
+		if e.domainMatch(host) {
+			cookies = append(cookies, e.toCookie())
+		}
+	}
+	return cookies
+}
+
+// Delete 删除 Domain、Path、Name 完全匹配的 Cookie，返回是否删除成功
+// domain 可以带前导点，path 为空时视为 /
+func (j *CookieJar) Delete(domain, path, name string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	if path == "" {
+		path = "/"
+	}
+
+	defer j.writeBack()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key := jarKey(domain, j.psList)
+	submap := j.entries[key]
+	id := (&entry{Domain: domain, Path: path, Name: name}).id()
+	if _, ok := submap[id]; !ok {
+		return false
+	}
+	delete(submap, id)
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	}
+	j.autoSave()
+	return true
+}
+
+// Clear 删除 CookieJar 中的所有 Cookie
+func (j *CookieJar) Clear() {
+	defer j.writeBack()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = make(map[string]map[string]entry)
+	j.autoSave()
+}
+
+// ClearDomain 删除属于 domain 及其子域名的所有 Cookie
+func (j *CookieJar) ClearDomain(domain string) {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	j.removeIf(func(e entry) bool {
+		return e.Domain == domain || hasDotSuffix(e.Domain, domain)
+	})
+}
+
+// ClearSession 删除所有会话 Cookie（未设置过期时间的 Cookie），模拟浏览器关闭
+func (j *CookieJar) ClearSession() {
+	j.removeIf(func(e entry) bool {
+		return !e.Persistent
+	})
+}
+
+// removeIf 删除所有满足条件的 Cookie
+func (j *CookieJar) removeIf(match func(e entry) bool) {
+	defer j.writeBack()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	modified := false
+	for key, submap := range j.entries {
+		for id, e := range submap {
+			if match(e) {
+				delete(submap, id)
+				modified = true
+			}
+		}
+		if len(submap) == 0 {
+			delete(j.entries, key)
+		}
+	}
+	if modified {
+		j.autoSave()
+	}
+}
+
+// toCookie 将 entry 转换为包含完整属性的 http.Cookie
+func (e *entry) toCookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Domain:   e.Domain,
+		Path:     e.Path,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+	}
+	if e.Persistent {
+		c.Expires = e.Expires
+	}
+	switch e.SameSite {
+	case "SameSite":
+		c.SameSite = http.SameSiteDefaultMode
+	case "SameSite=Strict":
+		c.SameSite = http.SameSiteStrictMode
+	case "SameSite=Lax":
+		c.SameSite = http.SameSiteLaxMode
+	case "SameSite=None":
+		c.SameSite = http.SameSiteNoneMode
+	}
+	return c
+}
@@ -0,0 +1,138 @@
+package httpc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnlyPrefix 为 Netscape 格式中标记 HttpOnly Cookie 的域名前缀
+const httpOnlyPrefix = "#HttpOnly_"
+
+// SaveNetscape 将 CookieJar 中的 Cookie 以 Netscape/Mozilla cookies.txt 格式写入 w
+// 生成的内容可直接被 curl、wget 等工具读取
+// 已过期的 Cookie 不会被写入，会话 Cookie 仅在开启 SetKeepSession 时以过期时间 0 写入
+func (j *CookieJar) SaveNetscape(w io.Writer) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("# Netscape HTTP Cookie File\n")
+	_, _ = bw.WriteString("# This file was generated by httpc. Edit at your own risk.\n\n")
+
+	now := time.Now()
+	for _, e := range j.sortedEntries() {
+		if e.Persistent && !e.Expires.After(now) {
+			continue
+		}
+		if !e.Persistent && !j.keepSession {
+			continue
+		}
+
+		domain := e.Domain
+		includeSubdomains := "FALSE"
+		if !e.HostOnly {
+			domain = "." + domain
+			includeSubdomains = "TRUE"
+		}
+		if e.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		secure := "FALSE"
+		if e.Secure {
+			secure = "TRUE"
+		}
+		var expires int64
+		if e.Persistent {
+			expires = e.Expires.Unix()
+		}
+
+		_, _ = fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSubdomains, e.Path, secure, expires, e.Name, e.Value)
+	}
+	return bw.Flush()
+}
+
+// LoadNetscape 从 r 中读取 Netscape/Mozilla cookies.txt 格式的 Cookie 并合并到 CookieJar 中
+// 支持 "#HttpOnly_" 前缀，过期时间为 0 的行视为会话 Cookie，已过期的 Cookie 会被忽略
+func (j *CookieJar) LoadNetscape(r io.Reader) error {
+	var entries []entry
+	now := time.Now()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := false
+		if strings.HasPrefix(text, httpOnlyPrefix) {
+			httpOnly = true
+			text = text[len(httpOnlyPrefix):]
+		} else if strings.HasPrefix(text, "#") || strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return fmt.Errorf("cookiejar: malformed cookies.txt line %d", line)
+		}
+
+		domain := strings.ToLower(fields[0])
+		includeSubdomains := strings.EqualFold(fields[1], "TRUE")
+		if strings.HasPrefix(domain, ".") {
+			domain = domain[1:]
+			includeSubdomains = true
+		}
+		if domain == "" {
+			return fmt.Errorf("cookiejar: empty domain in cookies.txt line %d", line)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cookiejar: invalid expiration in cookies.txt line %d", line)
+		}
+
+		e := entry{
+			Name:       fields[5],
+			Value:      fields[6],
+			Domain:     domain,
+			Path:       fields[2],
+			Secure:     strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly:   httpOnly,
+			HostOnly:   !includeSubdomains,
+			Creation:   now,
+			LastAccess: now,
+		}
+		if expires > 0 {
+			e.Expires = time.Unix(expires, 0)
+			e.Persistent = true
+			if !e.Expires.After(now) {
+				continue
+			}
+		} else {
+			e.Expires = endOfTime
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	defer j.writeBack()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, e := range entries {
+		e.seqNum = j.nextSeqNum
+		j.insert(e)
+	}
+	j.autoSave()
+	return nil
+}
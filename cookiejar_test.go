@@ -0,0 +1,119 @@
+package httpc
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEntryPathMatch(t *testing.T) {
+	tests := []struct {
+		cookiePath  string
+		requestPath string
+		want        bool
+	}{
+		{"/", "/", true},
+		{"/", "/app", true},
+		{"/app", "/app", true},
+		{"/app/", "/app/", true},
+		{"/app/", "/app/x", true},
+		{"/app/", "/app", false},
+		{"/app", "/app/x", true},
+		{"/app", "/app/x/y", true},
+		{"/app", "/apple", false},
+		{"/app", "/", false},
+		{"/app", "", false},
+		{"/", "", false},
+	}
+	for _, tt := range tests {
+		e := &entry{Path: tt.cookiePath}
+		if got := e.pathMatch(tt.requestPath); got != tt.want {
+			t.Errorf("entry{Path: %q}.pathMatch(%q) = %v, want %v", tt.cookiePath, tt.requestPath, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		strict bool
+		want   string
+	}{
+		{"/app/login", true, "/app"},
+		{"/app/", true, "/app"},
+		{"/login", true, "/"},
+		{"/", true, "/"},
+		{"", true, "/"},
+		{"noslash", true, "/"},
+		{"/app/login", false, "/"},
+	}
+	for _, tt := range tests {
+		if got := defaultPath(tt.path, tt.strict); got != tt.want {
+			t.Errorf("defaultPath(%q, %v) = %q, want %q", tt.path, tt.strict, got, tt.want)
+		}
+	}
+}
+
+func TestFileCookieJarAutoSave(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+	jar, err := NewFileCookieJar(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://example.com/")
+	persistent := &http.Cookie{Name: "p", Value: "1", Expires: time.Now().Add(time.Hour)}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "s", Value: "1"}})
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("session cookie written to file: %v", err)
+	}
+
+	jar.SetCookies(u, []*http.Cookie{persistent})
+	if _, err = os.Stat(filename); err != nil {
+		t.Fatalf("persistent cookie not written: %v", err)
+	}
+
+	// 只有 LastAccess 变化时不会重写文件
+	if err = os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(u, []*http.Cookie{persistent})
+	jar.SetCookies(u, []*http.Cookie{{Name: "s", Value: "2"}})
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("file rewritten without persistent change: %v", err)
+	}
+
+	if err = jar.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewFileCookieJar(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := loaded.All(); len(all) != 1 || all[0].Name != "p" {
+		t.Fatalf("loaded %v, want only p", all)
+	}
+}
+
+func TestFileCookieJarFlushError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	jar, err := NewFileCookieJar(filepath.Join(dir, "cookies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "p", Value: "1", Expires: time.Now().Add(time.Hour)}})
+
+	if err = os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = jar.Flush(); err == nil {
+		t.Fatal("Flush did not report the failed automatic write")
+	}
+	if err = jar.Flush(); err != nil {
+		t.Fatalf("second Flush: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package httpc
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decoder 定义响应体解码函数，用于处理 Content-Encoding
+// 参数 r 为编码后的数据流，返回解码后的数据流
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// defaultDecoders 返回内置的解码器，支持 gzip、deflate、br 与 zstd
+func defaultDecoders() map[string]Decoder {
+	gzipDecoder := func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	}
+	return map[string]Decoder{
+		"gzip":    gzipDecoder,
+		"x-gzip":  gzipDecoder,
+		"deflate": decodeDeflate,
+		"br": func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(brotli.NewReader(r)), nil
+		},
+		"zstd": func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	}
+}
+
+// decodeDeflate 解码 deflate 编码
+// 标准要求 deflate 为 zlib 格式，但部分服务端发送的是原始 deflate 数据，这里根据 zlib 头自动判断
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedBody 为解码后的响应体，关闭时依次关闭所有解码器与原始响应体
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (this *decodedBody) Close() error {
+	var err error
+	for i := len(this.closers) - 1; i >= 0; i-- {
+		if e := this.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// SetDecoder 为指定的 Content-Encoding 注册解码器，传入 nil 时移除该编码的解码器
+// 内置支持 gzip、deflate、br 与 zstd，可通过该方法替换或扩展
+func (this *HttpClient) SetDecoder(encoding string, d Decoder) *HttpClient {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if d == nil {
+		delete(this.decoders, encoding)
+	} else {
+		this.decoders[encoding] = d
+	}
+	return this
+}
+
+// decodeBody 根据 Content-Encoding 返回解码后的响应体
+// 多重编码（如 "gzip, br"）按照与编码相反的顺序依次解码，遇到未注册解码器的编码（如 compress）时停止解码，
+// 剩余部分原样返回并保留在 Content-Encoding 中；有编码被解码时会移除响应头中的 Content-Length
+func (this *HttpClient) decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	var encodings []string
+	for _, value := range resp.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+
+	n := len(encodings)
+	for n > 0 {
+		if _, ok := this.decoders[encodings[n-1]]; !ok {
+			break
+		}
+		n--
+	}
+	if n == len(encodings) {
+		return resp.Body, nil
+	}
+
+	body := &decodedBody{Reader: resp.Body, closers: []io.Closer{resp.Body}}
+	for i := len(encodings) - 1; i >= n; i-- {
+		reader, err := this.decoders[encodings[i]](body.Reader)
+		if err != nil {
+			return nil, errors.New(encodings[i] + " decode failed:" + err.Error())
+		}
+		body.Reader = reader
+		body.closers = append(body.closers, reader)
+	}
+
+	if n > 0 {
+		resp.Header.Set("Content-Encoding", strings.Join(encodings[:n], ", "))
+	} else {
+		resp.Header.Del("Content-Encoding")
+	}
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return body, nil
+}
@@ -0,0 +1,62 @@
+package httpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func gzipData(t *testing.T, data string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDecodeBodyUnknownEncoding(t *testing.T) {
+	tests := []struct {
+		encoding     string
+		body         string
+		want         string
+		wantEncoding string
+	}{
+		{"", "plain", "plain", ""},
+		{"gzip", gzipData(t, "hello"), "hello", ""},
+		{"compress", "raw", "raw", "compress"},
+		{"x-vendor", "raw", "raw", "x-vendor"},
+		{"compress, gzip", gzipData(t, "raw"), "raw", "compress"},
+		{"gzip, compress", "raw", "raw", "gzip, compress"},
+	}
+	client := NewHttpClient()
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString(tt.body))}
+		if tt.encoding != "" {
+			resp.Header.Set("Content-Encoding", tt.encoding)
+		}
+		reader, err := client.decodeBody(resp)
+		if err != nil {
+			t.Errorf("%q: %v", tt.encoding, err)
+			continue
+		}
+		data, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			t.Errorf("%q: %v", tt.encoding, err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("%q: body = %q, want %q", tt.encoding, data, tt.want)
+		}
+		if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("%q: Content-Encoding = %q, want %q", tt.encoding, got, tt.wantEncoding)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package httpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// partSuffix 为下载过程中临时文件的后缀，下载完成后重命名为目标文件
+	partSuffix = ".part"
+	// metaSuffix 为临时文件对应的断点信息文件后缀
+	metaSuffix = ".part.json"
+)
+
+// partialMeta 记录未完成下载的校验信息，用于断点续传时判断远端文件是否发生变化
+type partialMeta struct {
+	Url          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Length       int64  `json:"length"`
+}
+
+// resumeState 为断点续传时需要附加到请求上的信息
+type resumeState struct {
+	offset    int64
+	validator string
+	length    int64
+}
+
+// Download 发送请求并将响应体保存为文件，支持断点续传
+// savePath 为目录路径，saveFileName 可为空，自动根据 Content-Disposition 或 URL 获取文件名
+// 下载过程中数据写入 "文件名.part"，完成后原子地重命名为目标文件；
+// 若上次下载中断，会通过 Range 与 If-Range 从已下载的位置继续，远端文件的 ETag 或 Last-Modified 发生变化时重新下载
+// 未指定文件名时，临时文件以 URL 中的文件名命名；指定了文件名且为 CollisionSkip 模式时，文件已存在则不发送请求
+func (this *Request) Download(savePath, saveFileName string, ctxs ...context.Context) (*http.Response, error) {
+	partName := saveFileName
+	if partName == "" {
+		rawUrl, err := this.requestUrl()
+		if err != nil {
+			return nil, err
+		}
+		partName = urlSaveName(rawUrl)
+	}
+	partDest := filepath.Join(savePath, partName)
+	if saveFileName != "" && this.collision == CollisionSkip {
+		// 文件名已确定时提前检查，避免发送不需要的请求
+		if _, err := this.resolveCollision(partDest); err != nil {
+			return nil, err
+		}
+	}
+
+	state := loadResumeState(partDest)
+	this.resume = state
+	this.Send(ctxs...)
+	this.resume = nil
+	if this.err != nil {
+		return nil, this.err
+	}
+
+	defer func() {
+		_ = this.response.Body.Close()
+	}()
+
+	complete := this.response.StatusCode == http.StatusRequestedRangeNotSatisfiable && state.offset > 0 && state.offset == state.length
+	if !complete && this.response.StatusCode != http.StatusOK && this.response.StatusCode != http.StatusPartialContent {
+		return this.response, fmt.Errorf("failed to write: server responded with status %d", this.response.StatusCode)
+	}
+
+	destPath := partDest
+	if saveFileName == "" {
+		destPath = filepath.Join(savePath, this.responseFileName(this.response, this.request.URL.String()))
+	}
+	destPath, err := this.resolveCollision(destPath)
+	if err != nil {
+		return this.response, err
+	}
+
+	if complete {
+		return this.response, finishPartial(partDest, destPath)
+	}
+	return this.response, this.saveFile(partDest, destPath, state.offset, true)
+}
+
+// saveFile 将响应体写入 partDest 对应的临时文件，完成后重命名为 destPath
+// offset 大于 0 且服务端返回 206 时，将内容追加到已有的临时文件之后
+// resumable 为 false 时不保存断点信息，写入失败时删除临时文件
+func (this *Request) saveFile(partDest, destPath string, offset int64, resumable bool) (err error) {
+	resp := this.response
+	partPath := partDest + partSuffix
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 && resp.StatusCode == http.StatusPartialContent {
+		start, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return errors.New("failed to resume: unexpected Content-Range " + resp.Header.Get("Content-Range"))
+		}
+		flag = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+		if resumable {
+			savePartialMeta(partDest, this.request.URL.String(), resp)
+		}
+	}
+
+	resumePath := ""
+	if offset > 0 {
+		resumePath = partPath
+	}
+	verifier, err := this.newChecksumVerifier(resp, resumePath)
+	if err != nil {
+		return err
+	}
+
+	this.wrapDownload(resp, offset)
+
+	reader, err := this.httpc.decodeBody(resp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	file, err := os.OpenFile(partPath, flag, 0644)
+	if err != nil {
+		return err
+	}
+	if !resumable {
+		defer func() {
+			if err != nil {
+				_ = os.Remove(partPath)
+				_ = os.Remove(partDest + metaSuffix)
+			}
+		}()
+	}
+	var writer io.Writer = file
+	if verifier != nil {
+		writer = io.MultiWriter(file, verifier.hash)
+	}
+	_, err = io.Copy(writer, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if verifier != nil {
+		if err = verifier.verify(); err != nil {
+			_ = os.Remove(partPath)
+			_ = os.Remove(partDest + metaSuffix)
+			return err
+		}
+	}
+	return finishPartial(partDest, destPath)
+}
+
+// finishPartial 将 partDest 对应的临时文件重命名为 destPath 并删除断点信息
+func finishPartial(partDest, destPath string) error {
+	if err := os.Rename(partDest+partSuffix, destPath); err != nil {
+		return err
+	}
+	_ = os.Remove(partDest + metaSuffix)
+	return nil
+}
+
+// loadResumeState 根据已存在的临时文件与断点信息计算续传位置
+// 没有可用于校验的 ETag 或 Last-Modified 时不续传，从头开始下载
+func loadResumeState(destPath string) *resumeState {
+	state := &resumeState{}
+	info, err := os.Stat(destPath + partSuffix)
+	if err != nil || info.Size() == 0 {
+		return state
+	}
+	data, err := os.ReadFile(destPath + metaSuffix)
+	if err != nil {
+		return state
+	}
+	var meta partialMeta
+	if json.Unmarshal(data, &meta) != nil {
+		return state
+	}
+
+	switch {
+	case meta.ETag != "" && !strings.HasPrefix(meta.ETag, "W/"):
+		state.validator = meta.ETag
+	case meta.LastModified != "":
+		state.validator = meta.LastModified
+	default:
+		return state
+	}
+	state.offset = info.Size()
+	state.length = meta.Length
+	return state
+}
+
+// savePartialMeta 保存本次下载的校验信息，写入失败时仅影响后续续传
+func savePartialMeta(destPath, rawUrl string, resp *http.Response) {
+	meta := partialMeta{
+		Url:          rawUrl,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Length:       resp.ContentLength,
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok {
+			meta.Length = total
+		}
+	}
+	if meta.ETag == "" && meta.LastModified == "" {
+		_ = os.Remove(destPath + metaSuffix)
+		return
+	}
+	data, err := json.Marshal(meta)
+	if err == nil {
+		_ = os.WriteFile(destPath+metaSuffix, data, 0644)
+	}
+}
+
+// parseContentRange 解析 "bytes start-end/total" 格式的 Content-Range，total 未知时为 -1
+func parseContentRange(value string) (start, total int64, ok bool) {
+	var end int64
+	if n, _ := fmt.Sscanf(value, "bytes %d-%d/%d", &start, &end, &total); n == 3 {
+		return start, total, true
+	}
+	if n, _ := fmt.Sscanf(value, "bytes %d-%d/*", &start, &end); n == 2 {
+		return start, -1, true
+	}
+	return 0, 0, false
+}
+
+// urlFileName 返回 URL 路径的最后一段作为文件名，无法获取时返回 "download.tmp"
+func urlFileName(rawUrl string) string {
+	name := ""
+	u, err := url.Parse(rawUrl)
+	if err == nil {
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) > 0 {
+			name = parts[len(parts)-1]
+		}
+	}
+	if name == "" {
+		name = "download.tmp"
+	}
+	return name
+}
@@ -0,0 +1,57 @@
+package httpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEndFileRemovesPartialOnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 响应体短于 Content-Length，客户端读取时出错
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", "10")
+		_, _ = w.Write([]byte("abc"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if _, err := NewRequest(NewHttpClient()).SetUrl(srv.URL).Send().EndFile(dir, "file"); err == nil {
+		t.Fatal("expected error")
+	}
+	dest := filepath.Join(dir, "file")
+	for _, name := range []string{dest, dest + partSuffix, dest + metaSuffix} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s left behind", filepath.Base(name))
+		}
+	}
+}
+
+func TestDownloadSkipExistingWithoutRequest(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte("new"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "file")
+	if err := os.WriteFile(dest, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewRequest(NewHttpClient()).SetUrl(srv.URL).SetFileCollision(CollisionSkip).Download(dir, "file")
+	if !errors.Is(err, ErrFileExists) {
+		t.Fatalf("got %v, want ErrFileExists", err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("server received %d requests, want 0", n)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "old" {
+		t.Errorf("file overwritten: %q", data)
+	}
+}
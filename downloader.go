@@ -0,0 +1,269 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Downloader 基于 HttpClient 的分段并发下载器
+// 将文件按字节范围拆分为多个分段并发下载，失败的分段会从中断位置重试，
+// 服务端不支持 Range 请求时自动退化为单连接下载
+type Downloader struct {
+	client         *HttpClient
+	segments       int
+	minSegmentSize int64
+	retry          *RetryPolicy
+	header         headerList
+	progress       ProgressFunc
+	interval       time.Duration
+}
+
+// NewDownloader 创建一个分段下载器，默认 4 个分段，每个分段至少 1MB
+// 分段失败时按 NewRetryPolicy 的默认策略重试
+func NewDownloader(client *HttpClient) *Downloader {
+	return &Downloader{
+		client:         client,
+		segments:       4,
+		minSegmentSize: 1 << 20,
+		retry:          NewRetryPolicy(),
+		interval:       defaultProgressInterval,
+	}
+}
+
+// SetSegments 设置并发下载的分段数量，小于 1 时按 1 处理
+func (this *Downloader) SetSegments(n int) *Downloader {
+	if n < 1 {
+		n = 1
+	}
+	this.segments = n
+	return this
+}
+
+// SetMinSegmentSize 设置每个分段的最小字节数，文件较小时会相应减少分段数量
+func (this *Downloader) SetMinSegmentSize(size int64) *Downloader {
+	if size < 1 {
+		size = 1
+	}
+	this.minSegmentSize = size
+	return this
+}
+
+// SetRetry 设置分段下载失败时的重试策略，传入 nil 表示不重试
+// 重试时从分段已写入的位置继续下载
+func (this *Downloader) SetRetry(p *RetryPolicy) *Downloader {
+	this.retry = p
+	return this
+}
+
+// SetHeader 设置每个分段请求附带的请求头
+func (this *Downloader) SetHeader(name, value string) *Downloader {
+	this.header = this.header.set(name, value)
+	return this
+}
+
+// SetProgress 设置下载进度的回调函数，分段下载时汇总所有分段的进度，回调不会并发执行
+func (this *Downloader) SetProgress(f ProgressFunc) *Downloader {
+	this.progress = f
+	return this
+}
+
+// SetProgressInterval 设置进度回调的最小间隔，默认 200ms，为 0 时每次写入数据都会回调
+// 下载完成时无论间隔多少都会回调一次，且 Done 为 true
+func (this *Downloader) SetProgressInterval(d time.Duration) *Downloader {
+	this.interval = d
+	return this
+}
+
+// newRequest 创建附带下载器请求头的 GET 请求
+func (this *Downloader) newRequest(rawUrl string) *Request {
+	req := NewRequest(this.client).SetUrl(rawUrl)
+	for _, f := range this.header {
+		req.AddHeader(f.Name, f.Value)
+	}
+	return req.SetHeader("Accept-Encoding", "identity")
+}
+
+// Download 下载 rawUrl 并保存到 destPath
+// 下载过程中数据写入 "destPath.part"，全部分段完成后原子地重命名为 destPath
+// 可选传入 context，用于控制下载超时或取消
+func (this *Downloader) Download(rawUrl, destPath string, ctxs ...context.Context) error {
+	ctx := context.Background()
+	if len(ctxs) > 0 {
+		ctx = ctxs[0]
+	}
+
+	probe := this.newRequest(rawUrl).SetHeader("Range", "bytes=0-0").Send(ctx)
+	if probe.err != nil {
+		return probe.err
+	}
+	resp := probe.response
+
+	_, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if resp.StatusCode != http.StatusPartialContent || !ok || total <= 0 ||
+		strings.EqualFold(resp.Header.Get("Accept-Ranges"), "none") {
+		return this.downloadSingle(ctx, probe, rawUrl, destPath)
+	}
+	drainBody(resp)
+
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+
+	partPath := destPath + partSuffix
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err = file.Truncate(total); err != nil {
+		_ = file.Close()
+		_ = os.Remove(partPath)
+		return err
+	}
+
+	var progress *progressCounter
+	if this.progress != nil {
+		progress = newProgressCounter(total, this.interval, this.progress)
+	}
+	err = this.fetchSegments(ctx, rawUrl, validator, file, total, progress)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(partPath)
+		return err
+	}
+	_ = os.Remove(destPath + metaSuffix)
+	if err = os.Rename(partPath, destPath); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress.finish()
+	}
+	return nil
+}
+
+// downloadSingle 在无法分段下载时通过单个连接下载
+// 探测请求返回 200 时直接保存其响应体，返回的 206 无法确定文件大小时重新发送不带 Range 的请求
+func (this *Downloader) downloadSingle(ctx context.Context, probe *Request, rawUrl, destPath string) error {
+	if probe.response.StatusCode == http.StatusPartialContent {
+		drainBody(probe.response)
+		if probe = this.newRequest(rawUrl).Send(ctx); probe.err != nil {
+			return probe.err
+		}
+	}
+	if probe.response.StatusCode != http.StatusOK {
+		drainBody(probe.response)
+		return fmt.Errorf("failed to download: server responded with status %d", probe.response.StatusCode)
+	}
+	if this.progress != nil {
+		probe.SetDownloadProgress(this.progress).SetProgressInterval(this.interval)
+	}
+	_, err := probe.EndFile(filepath.Dir(destPath), filepath.Base(destPath))
+	return err
+}
+
+// fetchSegments 将 [0, total) 拆分为多个分段并发下载，任一分段最终失败时取消其余分段
+func (this *Downloader) fetchSegments(ctx context.Context, rawUrl, validator string, file *os.File, total int64, progress *progressCounter) error {
+	count := int64(this.segments)
+	if max := total / this.minSegmentSize; max < count {
+		count = max
+	}
+	if count < 1 {
+		count = 1
+	}
+	size := total / count
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := int64(0); i < count; i++ {
+		start, end := i*size, (i+1)*size-1
+		if i == count-1 {
+			end = total - 1
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := this.fetchSegment(ctx, rawUrl, validator, file, start, end, progress); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// fetchSegment 下载 [start, end] 范围的数据并写入文件对应位置，失败时按重试策略从已写入的位置继续
+func (this *Downloader) fetchSegment(ctx context.Context, rawUrl, validator string, file *os.File, start, end int64, progress *progressCounter) error {
+	written := int64(0)
+	for attempt := 1; ; attempt++ {
+		n, err := this.fetchRange(ctx, rawUrl, validator, file, start+written, end, progress)
+		written += n
+		if err == nil {
+			return nil
+		}
+		if this.retry == nil || attempt >= this.retry.maxAttempts || ctx.Err() != nil || errors.Is(err, errRangeChanged) {
+			return err
+		}
+		if err = sleepContext(ctx, this.retry.backoff(attempt, nil)); err != nil {
+			return err
+		}
+	}
+}
+
+// errRangeChanged 表示下载过程中远端文件发生了变化
+var errRangeChanged = errors.New("failed to download: remote file changed during download")
+
+// fetchRange 请求 [start, end] 范围的数据并写入文件，返回实际写入的字节数
+func (this *Downloader) fetchRange(ctx context.Context, rawUrl, validator string, file *os.File, start, end int64, progress *progressCounter) (int64, error) {
+	req := this.newRequest(rawUrl).SetHeader("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != "" {
+		req.SetHeader("If-Range", validator)
+	}
+	req.Send(ctx)
+	if req.err != nil {
+		return 0, req.err
+	}
+	resp := req.response
+	defer drainBody(resp)
+
+	if resp.StatusCode == http.StatusOK {
+		return 0, errRangeChanged
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("failed to download segment: server responded with status %d", resp.StatusCode)
+	}
+	if got, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || got != start {
+		return 0, errors.New("failed to download segment: unexpected Content-Range " + resp.Header.Get("Content-Range"))
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return 0, errors.New("failed to download segment: unexpected Content-Encoding " + encoding)
+	}
+
+	var writer io.Writer = io.NewOffsetWriter(file, start)
+	if progress != nil {
+		writer = &progressWriter{Writer: writer, counter: progress}
+	}
+	n, err := io.Copy(writer, io.LimitReader(resp.Body, end-start+1))
+	if err == nil && n < end-start+1 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
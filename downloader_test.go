@@ -0,0 +1,87 @@
+package httpc
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloaderUnknownTotalFallback(t *testing.T) {
+	content := strings.Repeat("x", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-0/*")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(content[:1]))
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	var last Progress
+	dest := filepath.Join(t.TempDir(), "file")
+	err := NewDownloader(NewHttpClient()).SetProgress(func(p Progress) {
+		last = p
+	}).Download(srv.URL, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Fatalf("got %d bytes, want %d", len(data), len(content))
+	}
+	if !last.Done || last.Transferred != int64(len(content)) {
+		t.Errorf("last progress %+v", last)
+	}
+}
+
+func TestDownloaderSegmentProgress(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	var (
+		mu      sync.Mutex
+		reports []Progress
+	)
+	dest := filepath.Join(t.TempDir(), "file")
+	err := NewDownloader(NewHttpClient()).
+		SetSegments(4).
+		SetMinSegmentSize(1000).
+		SetProgressInterval(0).
+		SetProgress(func(p Progress) {
+			mu.Lock()
+			reports = append(reports, p)
+			mu.Unlock()
+		}).
+		Download(srv.URL, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatal("downloaded content mismatch")
+	}
+	if len(reports) < 2 {
+		t.Fatalf("got %d progress reports", len(reports))
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Transferred != int64(len(content)) || last.Total != int64(len(content)) {
+		t.Errorf("last progress %+v", last)
+	}
+}
@@ -0,0 +1,156 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileCollision 定义保存文件时目标文件已存在的处理方式
+type FileCollision int
+
+const (
+	// CollisionOverwrite 覆盖已存在的文件，默认方式
+	CollisionOverwrite FileCollision = iota
+	// CollisionRename 在文件名后追加序号，例如 1.zip 已存在时保存为 1(1).zip
+	CollisionRename
+	// CollisionSkip 跳过下载并返回 ErrFileExists
+	CollisionSkip
+)
+
+// ErrFileExists 在 CollisionSkip 模式下目标文件已存在时返回
+var ErrFileExists = errors.New("failed to write: file already exists")
+
+// maxFileNameLen 为文件名的最大字节数
+const maxFileNameLen = 255
+
+// preferredExtensions 为常见 Content-Type 对应的首选扩展名
+var preferredExtensions = map[string]string{
+	"application/json":         ".json",
+	"application/octet-stream": ".bin",
+	"application/pdf":          ".pdf",
+	"application/xml":          ".xml",
+	"application/zip":          ".zip",
+	"application/gzip":         ".gz",
+	"image/gif":                ".gif",
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/svg+xml":            ".svg",
+	"image/webp":               ".webp",
+	"text/css":                 ".css",
+	"text/csv":                 ".csv",
+	"text/html":                ".html",
+	"text/javascript":          ".js",
+	"text/plain":               ".txt",
+	"text/xml":                 ".xml",
+	"video/mp4":                ".mp4",
+}
+
+// SetFileCollision 设置 EndFile 与 Download 在目标文件已存在时的处理方式，默认覆盖
+func (this *Request) SetFileCollision(c FileCollision) *Request {
+	this.collision = c
+	return this
+}
+
+// SetExtFromContentType 设置自动获取的文件名缺少扩展名时，是否根据响应的 Content-Type 补充扩展名
+func (this *Request) SetExtFromContentType(b bool) *Request {
+	this.extFromType = b
+	return this
+}
+
+// responseFileName 根据响应确定保存的文件名
+// 优先使用 Content-Disposition 中的 filename* 与 filename（RFC 6266），其次使用 URL 路径的最后一段，
+// 自动获取的文件名会去除路径与非法字符，防止写入 savePath 以外的目录
+func (this *Request) responseFileName(resp *http.Response, rawUrl string) string {
+	name := ""
+	if cd := resp.Header.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil {
+			name = sanitizeFileName(params["filename"])
+		}
+	}
+	if name == "" {
+		name = urlSaveName(rawUrl)
+	}
+	if this.extFromType && filepath.Ext(name) == "" {
+		name += extensionByType(resp.Header.Get("Content-Type"))
+	}
+	return name
+}
+
+// urlSaveName 返回 URL 路径最后一段经过清理后的文件名，无法获取时返回 "download.tmp"
+func urlSaveName(rawUrl string) string {
+	if name := sanitizeFileName(urlFileName(rawUrl)); name != "" {
+		return name
+	}
+	return "download.tmp"
+}
+
+// resolveCollision 按照冲突处理方式返回最终的保存路径
+func (this *Request) resolveCollision(destPath string) (string, error) {
+	if _, err := os.Stat(destPath); err != nil {
+		return destPath, nil
+	}
+	switch this.collision {
+	case CollisionSkip:
+		return "", ErrFileExists
+	case CollisionRename:
+		ext := filepath.Ext(destPath)
+		base := strings.TrimSuffix(destPath, ext)
+		for i := 1; ; i++ {
+			candidate := fmt.Sprintf("%s(%d)%s", base, i, ext)
+			if _, err := os.Stat(candidate); err != nil {
+				return candidate, nil
+			}
+		}
+	}
+	return destPath, nil
+}
+
+// sanitizeFileName 去除文件名中的路径、控制字符与各平台的保留字符
+// 结果为空或为 "."、".." 时返回空字符串
+func sanitizeFileName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	for len(name) > maxFileNameLen {
+		ext := filepath.Ext(name)
+		if len(ext) >= maxFileNameLen {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFileNameLen-len(ext)], "") + ext
+	}
+	if name == "" || name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// extensionByType 根据 Content-Type 返回扩展名，无法识别时返回空字符串
+func extensionByType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -2,3 +2,9 @@ module github.com/Albert-Zhan/httpc
 
 go 1.23
 
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.17.11
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
+)
@@ -0,0 +1,123 @@
+package httpc
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// headerField 为一个请求头字段，Name 保留调用者传入的大小写
+type headerField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// headerList 为按添加顺序保存的请求头，同名字段可以出现多次
+type headerList []headerField
+
+// headerOrderKey 为在请求 context 中保存请求头顺序的键
+type headerOrderKey struct{}
+
+// set 替换第一个同名字段的值并删除其余同名字段，不存在时追加到末尾，名称不区分大小写
+func (h headerList) set(name, value string) headerList {
+	found := false
+	result := h[:0]
+	for _, f := range h {
+		if !strings.EqualFold(f.Name, name) {
+			result = append(result, f)
+		} else if !found {
+			found = true
+			result = append(result, headerField{Name: name, Value: value})
+		}
+	}
+	if !found {
+		result = append(result, headerField{Name: name, Value: value})
+	}
+	return result
+}
+
+// add 追加一个字段
+func (h headerList) add(name, value string) headerList {
+	return append(h, headerField{Name: name, Value: value})
+}
+
+// del 删除全部同名字段
+func (h headerList) del(name string) headerList {
+	result := h[:0]
+	for _, f := range h {
+		if !strings.EqualFold(f.Name, name) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// clone 返回副本
+func (h headerList) clone() headerList {
+	return append(headerList(nil), h...)
+}
+
+// apply 将字段写入 http.Header，同名字段会替换 header 中已有的值
+func (h headerList) apply(header http.Header) {
+	seen := make(map[string]bool, len(h))
+	for _, f := range h {
+		key := textproto.CanonicalMIMEHeaderKey(f.Name)
+		if !seen[key] {
+			seen[key] = true
+			delete(header, key)
+		}
+		header[key] = append(header[key], f.Value)
+	}
+}
+
+// names 返回按顺序排列的字段名称，同名字段出现多次时名称也会出现多次
+func (h headerList) names() []string {
+	names := make([]string, len(h))
+	for i, f := range h {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// applyHeaders 将请求头写入 request
+// net/http 会忽略 http.Header 中的 Host、Content-Length 与 Transfer-Encoding，这些请求头会被写入 http.Request 对应的字段；
+// Transfer-Encoding 只支持 chunked，Trailer 不支持，遇到无法发送的请求头时返回错误而不是忽略
+func (h headerList) applyHeaders(request *http.Request) error {
+	var regular headerList
+	hasLength, chunked := false, false
+	for _, f := range h {
+		value := strings.TrimSpace(f.Value)
+		switch textproto.CanonicalMIMEHeaderKey(f.Name) {
+		case "Host":
+			request.Host = value
+		case "Content-Length":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 {
+				return errors.New("invalid Content-Length header: " + f.Value)
+			}
+			if n == 0 && request.Body != nil && request.Body != http.NoBody {
+				return errors.New("Content-Length 0 set for a request with body")
+			}
+			request.ContentLength = n
+			hasLength = true
+		case "Transfer-Encoding":
+			if !strings.EqualFold(value, "chunked") {
+				return errors.New("unsupported Transfer-Encoding header: " + f.Value)
+			}
+			request.TransferEncoding = []string{"chunked"}
+			request.ContentLength = -1
+			chunked = true
+		case "Trailer":
+			return errors.New("unsupported header: Trailer")
+		default:
+			regular = append(regular, f)
+		}
+	}
+	if hasLength && chunked {
+		return errors.New("Content-Length and Transfer-Encoding cannot both be set")
+	}
+	regular.apply(request.Header)
+	return nil
+}
@@ -0,0 +1,85 @@
+package httpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RoundTripperFunc 将普通函数适配为 http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware 定义请求/响应拦截中间件
+// 中间件接收下一个 RoundTripper 并返回包装后的 RoundTripper，
+// 可在调用 next 前修改请求，在调用 next 后检查或替换响应
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// chainMiddleware 将多组中间件依次包装到 base 上
+// 先注册的中间件位于最外层，最先处理请求、最后处理响应
+func chainMiddleware(base http.RoundTripper, groups ...[]Middleware) http.RoundTripper {
+	var all []Middleware
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		base = all[i](base)
+	}
+	return base
+}
+
+// HeaderDefaults 返回一个为请求设置默认头信息的中间件
+// 仅当请求中不存在对应的头信息时才会设置，不会覆盖请求自身的值
+func HeaderDefaults(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var cloned *http.Request
+			for k, v := range headers {
+				if req.Header.Get(k) != "" {
+					continue
+				}
+				if cloned == nil {
+					cloned = req.Clone(req.Context())
+				}
+				cloned.Header.Set(k, v)
+			}
+			if cloned != nil {
+				req = cloned
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// RequestID 返回一个为每个请求生成唯一请求 ID 的中间件
+// 参数 header 为请求 ID 所使用的头名称，为空时使用 "X-Request-Id"
+// 参数 generator 为 ID 生成函数，为 nil 时生成 32 位随机十六进制字符串
+// 若请求中已存在该头信息，则保持原值不变
+func RequestID(header string, generator func() string) Middleware {
+	if header == "" {
+		header = "X-Request-Id"
+	}
+	if generator == nil {
+		generator = randomID
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(header, generator())
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// randomID 生成 16 字节的随机十六进制字符串
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
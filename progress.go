@@ -0,0 +1,184 @@
+package httpc
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Progress 描述一次上传或下载的传输进度
+type Progress struct {
+	// Transferred 已传输的字节数，断点续传时包含此前已下载的部分
+	Transferred int64
+	// Total 总字节数，未知时为 -1
+	Total int64
+	// Rate 本次传输的平均速率，单位为字节/秒
+	Rate float64
+	// ETA 预计剩余时间，总字节数未知或速率为 0 时为 -1
+	ETA time.Duration
+	// Done 传输是否已结束
+	Done bool
+}
+
+// ProgressFunc 定义进度回调函数
+type ProgressFunc func(p Progress)
+
+// defaultProgressInterval 为进度回调的默认最小间隔
+const defaultProgressInterval = 200 * time.Millisecond
+
+// SetUploadProgress 设置请求体上传进度的回调函数
+func (this *Request) SetUploadProgress(f ProgressFunc) *Request {
+	this.uploadProgress = f
+	return this
+}
+
+// SetDownloadProgress 设置响应体下载进度的回调函数
+// 作用于 End、EndByte、EndFile、Download 与 Do 读取响应体的过程
+func (this *Request) SetDownloadProgress(f ProgressFunc) *Request {
+	this.downloadProgress = f
+	return this
+}
+
+// SetProgressInterval 设置进度回调的最小间隔，默认 200ms，为 0 时每次读取数据都会回调
+// 传输结束时无论间隔多少都会回调一次，且 Done 为 true
+func (this *Request) SetProgressInterval(d time.Duration) *Request {
+	this.progressInterval = d
+	return this
+}
+
+// wrapUpload 为请求体包装进度统计
+func (this *Request) wrapUpload(request *http.Request) {
+	if this.uploadProgress == nil || request.Body == nil || request.Body == http.NoBody {
+		return
+	}
+	total := request.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+	request.Body = newProgressReader(request.Body, 0, total, this.progressInterval, this.uploadProgress)
+	if getBody := request.GetBody; getBody != nil {
+		request.GetBody = func() (io.ReadCloser, error) {
+			rc, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newProgressReader(rc, 0, total, this.progressInterval, this.uploadProgress), nil
+		}
+	}
+}
+
+// wrapDownload 为响应体包装进度统计，offset 为断点续传时已下载的字节数
+func (this *Request) wrapDownload(resp *http.Response, offset int64) {
+	if this.downloadProgress == nil {
+		return
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	resp.Body = newProgressReader(resp.Body, offset, total, this.progressInterval, this.downloadProgress)
+}
+
+// progressReader 在读取数据时统计进度并按间隔回调
+type progressReader struct {
+	io.ReadCloser
+	callback    ProgressFunc
+	interval    time.Duration
+	initial     int64
+	transferred int64
+	total       int64
+	start       time.Time
+	last        time.Time
+	done        bool
+}
+
+func newProgressReader(rc io.ReadCloser, offset, total int64, interval time.Duration, callback ProgressFunc) *progressReader {
+	now := time.Now()
+	return &progressReader{
+		ReadCloser:  rc,
+		callback:    callback,
+		interval:    interval,
+		initial:     offset,
+		transferred: offset,
+		total:       total,
+		start:       now,
+		last:        now,
+	}
+}
+
+func (this *progressReader) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	this.transferred += int64(n)
+	if err == io.EOF {
+		this.report(true)
+	} else if now := time.Now(); n > 0 && now.Sub(this.last) >= this.interval {
+		this.last = now
+		this.report(false)
+	}
+	return n, err
+}
+
+// report 计算速率与剩余时间并回调，传输结束的回调只会触发一次
+func (this *progressReader) report(done bool) {
+	if this.done {
+		return
+	}
+	this.done = done
+	p := Progress{
+		Transferred: this.transferred,
+		Total:       this.total,
+		ETA:         -1,
+		Done:        done,
+	}
+	if elapsed := time.Since(this.start).Seconds(); elapsed > 0 {
+		p.Rate = float64(this.transferred-this.initial) / elapsed
+	}
+	if done {
+		p.ETA = 0
+	} else if this.total >= 0 && p.Rate > 0 {
+		p.ETA = time.Duration(float64(this.total-this.transferred) / p.Rate * float64(time.Second))
+	}
+	this.callback(p)
+}
+
+// progressCounter 汇总多个分段写入的字节数并回调进度，并发安全
+type progressCounter struct {
+	mu     sync.Mutex
+	reader *progressReader
+}
+
+func newProgressCounter(total int64, interval time.Duration, callback ProgressFunc) *progressCounter {
+	return &progressCounter{reader: newProgressReader(nil, 0, total, interval, callback)}
+}
+
+// add 累加已写入的字节数，距上次回调超过间隔时回调
+func (this *progressCounter) add(n int64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	r := this.reader
+	r.transferred += n
+	if now := time.Now(); n > 0 && now.Sub(r.last) >= r.interval {
+		r.last = now
+		r.report(false)
+	}
+}
+
+// finish 回调传输结束
+func (this *progressCounter) finish() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.reader.report(true)
+}
+
+// progressWriter 在写入数据时累加到 progressCounter
+type progressWriter struct {
+	io.Writer
+	counter *progressCounter
+}
+
+func (this *progressWriter) Write(p []byte) (int, error) {
+	n, err := this.Writer.Write(p)
+	this.counter.add(int64(n))
+	return n, err
+}
@@ -0,0 +1,118 @@
+package httpc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// PublicSuffixList 提供域名的公共后缀（public suffix）查询
+// 与 net/http/cookiejar.PublicSuffixList 的定义一致，可直接使用 golang.org/x/net/publicsuffix.List
+// CookieJar 依赖它确定可注册域名（eTLD+1），并拒绝为 .co.uk 这类公共后缀设置 Cookie
+type PublicSuffixList interface {
+	// PublicSuffix 返回 domain 的公共后缀，例如 "www.example.co.uk" 返回 "co.uk"
+	PublicSuffix(domain string) string
+	// String 返回该列表的来源描述
+	String() string
+}
+
+// DefaultPublicSuffixList 返回默认的公共后缀列表，即 golang.org/x/net/publicsuffix.List
+// 需要使用更新的列表时可通过 LoadPublicSuffixList 从本地文件加载
+func DefaultPublicSuffixList() PublicSuffixList {
+	return publicsuffix.List
+}
+
+// LoadPublicSuffixList 从本地文件加载公共后缀列表
+// 文件格式与 https://publicsuffix.org/list/public_suffix_list.dat 相同，可用于替换默认列表
+func LoadPublicSuffixList(filename string) (PublicSuffixList, error) {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return parsePublicSuffixList(fd, filename)
+}
+
+// ParsePublicSuffixList 从 r 中解析 public_suffix_list.dat 格式的公共后缀列表
+func ParsePublicSuffixList(r io.Reader) (PublicSuffixList, error) {
+	return parsePublicSuffixList(r, "custom")
+}
+
+// suffixList 为从 public_suffix_list.dat 格式解析得到的 PublicSuffixList
+type suffixList struct {
+	name       string
+	rules      map[string]bool
+	wildcards  map[string]bool
+	exceptions map[string]bool
+}
+
+func parsePublicSuffixList(r io.Reader, name string) (*suffixList, error) {
+	list := &suffixList{
+		name:       name,
+		rules:      make(map[string]bool),
+		wildcards:  make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		target := list.rules
+		switch {
+		case strings.HasPrefix(line, "!"):
+			target, line = list.exceptions, line[1:]
+		case strings.HasPrefix(line, "*."):
+			target, line = list.wildcards, line[2:]
+		}
+
+		rule, err := toASCII(strings.ToLower(line))
+		if err != nil {
+			return nil, fmt.Errorf("publicsuffix: invalid rule %q: %v", line, err)
+		}
+		target[rule] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(list.rules)+len(list.wildcards) == 0 {
+		return nil, fmt.Errorf("publicsuffix: no rules found in %s", name)
+	}
+	return list, nil
+}
+
+// PublicSuffix 按照 publicsuffix.org 的匹配算法返回 domain 的公共后缀
+// 例外规则优先，其次取最长匹配的规则，都不匹配时以最后一个标签作为公共后缀
+func (l *suffixList) PublicSuffix(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	labels := strings.Split(domain, ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if l.exceptions[candidate] {
+			return strings.Join(labels[i+1:], ".")
+		}
+		if l.rules[candidate] {
+			return candidate
+		}
+		if i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")] {
+			return candidate
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// String 返回列表的来源描述
+func (l *suffixList) String() string {
+	return l.name
+}
@@ -0,0 +1,75 @@
+package httpc
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPublicSuffixList = `// comment
+com
+uk
+co.uk
+*.ck
+!www.ck
+*.kawasaki.jp
+!city.kawasaki.jp
+// ===BEGIN PRIVATE DOMAINS===
+blogspot.com
+`
+
+func TestParsePublicSuffixList(t *testing.T) {
+	list, err := ParsePublicSuffixList(strings.NewReader(testPublicSuffixList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"com", "com"},
+		{"example.com", "com"},
+		{"www.example.com", "com"},
+		{"Example.COM.", "com"},
+		{"example.co.uk", "co.uk"},
+		{"example.uk", "uk"},
+		{"foo.blogspot.com", "blogspot.com"},
+		{"ck", "ck"},
+		{"test.ck", "test.ck"},
+		{"b.test.ck", "test.ck"},
+		{"www.ck", "ck"},
+		{"a.www.ck", "ck"},
+		{"kawasaki.jp", "jp"},
+		{"foo.kawasaki.jp", "foo.kawasaki.jp"},
+		{"city.kawasaki.jp", "kawasaki.jp"},
+		{"www.city.kawasaki.jp", "kawasaki.jp"},
+		{"example.test", "test"},
+	}
+	for _, tt := range tests {
+		if got := list.PublicSuffix(tt.domain); got != tt.want {
+			t.Errorf("PublicSuffix(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestParsePublicSuffixListEmpty(t *testing.T) {
+	if _, err := ParsePublicSuffixList(strings.NewReader("// only comments\n")); err == nil {
+		t.Fatal("expected error for list without rules")
+	}
+}
+
+func TestDefaultPublicSuffixList(t *testing.T) {
+	list := DefaultPublicSuffixList()
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"www.example.co.uk", "co.uk"},
+		{"foo.www.ck", "ck"},
+		{"foo.bar.ck", "bar.ck"},
+	}
+	for _, tt := range tests {
+		if got := list.PublicSuffix(tt.domain); got != tt.want {
+			t.Errorf("PublicSuffix(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
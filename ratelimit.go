@@ -0,0 +1,341 @@
+package httpc
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minRateFactor 为自适应限速时速率可降低到的最小比例
+	minRateFactor = 1.0 / 16
+	// defaultBackoff 为 429 响应未携带 Retry-After 时暂停该主机的时间
+	defaultBackoff = time.Second
+	// stateSweepInterval 为清理空闲主机状态的间隔
+	stateSweepInterval = time.Minute
+)
+
+// tokenBucket 令牌桶，按固定速率生成令牌，最多积累 burst 个
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve 以 factor 调整后的速率预约一个令牌，返回需要等待的时间
+func (b *tokenBucket) reserve(now time.Time, factor float64) time.Duration {
+	rate := b.rate * factor
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// refund 归还一个已预约但未使用的令牌
+func (b *tokenBucket) refund() {
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// full 判断令牌桶在 now 时是否已补满
+func (b *tokenBucket) full(now time.Time, factor float64) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate*factor >= b.burst
+}
+
+// hostRule 为匹配主机名的限速与并发规则
+type hostRule struct {
+	pattern     string
+	rate        float64
+	burst       int
+	concurrency int
+}
+
+// hostState 为单个主机的限速状态
+// 主机没有限速规则时，降速期间使用 throttled 为 true 的临时令牌桶，速率恢复后删除
+type hostState struct {
+	bucket      *tokenBucket
+	sem         chan struct{}
+	factor      float64
+	pausedUntil time.Time
+	active      int
+	throttled   bool
+}
+
+// idle 判断主机状态是否可以丢弃：没有进行中的请求、未被暂停与降速且令牌已补满，调用者需持有锁
+func (s *hostState) idle(now time.Time) bool {
+	return s.active == 0 && !s.pausedUntil.After(now) && s.factor >= 1 &&
+		(s.bucket == nil || s.bucket.full(now, s.factor))
+}
+
+// rateLimiter 实现全局与按主机的令牌桶限速、按主机的并发限制，并根据 429 与 Retry-After 自适应降速
+type rateLimiter struct {
+	mu        sync.Mutex
+	global    *tokenBucket
+	rules     []hostRule
+	hosts     map[string]*hostState
+	adaptive  bool
+	lastSweep time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{hosts: make(map[string]*hostState), adaptive: true, lastSweep: time.Now()}
+}
+
+// matchHost 判断主机名是否匹配规则，支持 "*"、"example.com" 与 "*.example.com" 等通配写法
+func matchHost(pattern, host string) bool {
+	if pattern == host {
+		return true
+	}
+	matched, err := path.Match(pattern, host)
+	return err == nil && matched
+}
+
+// state 返回主机的限速状态，首次访问时根据规则创建，调用者需持有锁
+func (l *rateLimiter) state(host string) *hostState {
+	if s, ok := l.hosts[host]; ok {
+		return s
+	}
+	s := &hostState{factor: 1}
+	for _, rule := range l.rules {
+		if !matchHost(rule.pattern, host) {
+			continue
+		}
+		if rule.rate > 0 && s.bucket == nil {
+			s.bucket = newTokenBucket(rule.rate, rule.burst)
+		}
+		if rule.concurrency > 0 && s.sem == nil {
+			s.sem = make(chan struct{}, rule.concurrency)
+		}
+	}
+	l.hosts[host] = s
+	return s
+}
+
+// addRule 添加规则并清空已创建的主机状态，使新规则生效
+func (l *rateLimiter) addRule(rule hostRule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, r := range l.rules {
+		if r.pattern == rule.pattern {
+			if rule.rate <= 0 {
+				rule.rate, rule.burst = r.rate, r.burst
+			}
+			if rule.concurrency <= 0 {
+				rule.concurrency = r.concurrency
+			}
+			l.rules[i] = rule
+			l.hosts = make(map[string]*hostState)
+			return
+		}
+	}
+	l.rules = append(l.rules, rule)
+	l.hosts = make(map[string]*hostState)
+}
+
+// wait 预约令牌并计算本次请求需要等待的时间，返回主机状态与预约了令牌的全局令牌桶
+// 请求结束后需调用 done，未发送时需调用 cancel 归还令牌
+func (l *rateLimiter) wait(host string) (time.Duration, *hostState, *tokenBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= stateSweepInterval {
+		l.sweep(now)
+	}
+	s := l.state(host)
+	s.active++
+	var wait time.Duration
+	if s.pausedUntil.After(now) {
+		wait = s.pausedUntil.Sub(now)
+	}
+	if l.global != nil {
+		wait = max(wait, l.global.reserve(now, 1))
+	}
+	if s.bucket != nil {
+		wait = max(wait, s.bucket.reserve(now, s.factor))
+	}
+	return wait, s, l.global
+}
+
+// cancel 在请求未发送时归还 wait 预约的令牌
+func (l *rateLimiter) cancel(s *hostState, global *tokenBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if global != nil && global == l.global {
+		global.refund()
+	}
+	if s.bucket != nil {
+		s.bucket.refund()
+	}
+	s.active--
+}
+
+// done 在请求结束后减少主机进行中的请求数
+func (l *rateLimiter) done(s *hostState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s.active--
+}
+
+// sweep 删除空闲的主机状态，避免访问大量主机时状态无限增长，调用者需持有锁
+func (l *rateLimiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for host, s := range l.hosts {
+		if s.idle(now) {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+// observe 根据响应调整主机的速率：429 或携带 Retry-After 的 503 时降速并暂停，其它响应逐步恢复
+// 主机没有限速规则时以全局速率为基准创建临时令牌桶，未设置全局速率时以暂停时间内一个请求为基准
+func (l *rateLimiter) observe(s *hostState, resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.adaptive {
+		return
+	}
+
+	after, hasAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	throttled := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusServiceUnavailable && hasAfter)
+	if !throttled {
+		s.factor = math.Min(1, s.factor*1.25)
+		if s.factor >= 1 && s.throttled {
+			s.bucket, s.throttled = nil, false
+		}
+		return
+	}
+	if !hasAfter {
+		after = defaultBackoff
+	}
+	now := time.Now()
+	if until := now.Add(after); until.After(s.pausedUntil) {
+		s.pausedUntil = until
+	}
+	if s.bucket == nil {
+		rate := 1 / math.Max(after.Seconds(), defaultBackoff.Seconds())
+		if l.global != nil {
+			rate = l.global.rate
+		}
+		s.bucket, s.throttled = newTokenBucket(rate, 1), true
+	}
+	s.factor = math.Max(minRateFactor, s.factor/2)
+}
+
+// wrap 返回带限速的 RoundTripper
+func (l *rateLimiter) wrap(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		wait, s, global := l.wait(host)
+		if err := sleepContext(req.Context(), wait); err != nil {
+			l.cancel(s, global)
+			return nil, err
+		}
+
+		if s.sem != nil {
+			select {
+			case s.sem <- struct{}{}:
+			case <-req.Context().Done():
+				l.cancel(s, global)
+				return nil, req.Context().Err()
+			}
+		}
+		var once sync.Once
+		release := func() {
+			once.Do(func() {
+				if s.sem != nil {
+					<-s.sem
+				}
+				l.done(s)
+			})
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		l.observe(s, resp)
+		if resp.Body == nil || resp.Body == http.NoBody {
+			release()
+		} else {
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+		}
+		return resp, nil
+	})
+}
+
+// releaseBody 在响应体关闭时释放并发名额
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (this *releaseBody) Close() error {
+	err := this.ReadCloser.Close()
+	this.release()
+	return err
+}
+
+// rateLimiter 返回客户端的限速器，不存在时创建
+func (this *HttpClient) rateLimiter() *rateLimiter {
+	if this.limiter == nil {
+		this.limiter = newRateLimiter()
+	}
+	return this.limiter
+}
+
+// SetRateLimit 设置客户端全局的请求速率，rps 为每秒请求数，burst 为允许的突发请求数
+// rps 小于等于 0 时取消全局限速
+func (this *HttpClient) SetRateLimit(rps float64, burst int) *HttpClient {
+	l := this.rateLimiter()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rps <= 0 {
+		l.global = nil
+	} else {
+		l.global = newTokenBucket(rps, burst)
+	}
+	return this
+}
+
+// SetHostRateLimit 设置匹配 pattern 的每个主机的请求速率，rps 为每秒请求数，burst 为允许的突发请求数
+// pattern 可以是主机名或通配写法，例如 "api.example.com"、"*.example.com"、"*"
+// 每个匹配的主机单独计算速率，多个规则匹配同一主机时以先添加的为准
+func (this *HttpClient) SetHostRateLimit(pattern string, rps float64, burst int) *HttpClient {
+	this.rateLimiter().addRule(hostRule{pattern: strings.ToLower(pattern), rate: rps, burst: burst})
+	return this
+}
+
+// SetHostConcurrency 设置匹配 pattern 的每个主机同时进行中的最大请求数
+// 请求在响应体关闭后才会释放名额，pattern 的写法与 SetHostRateLimit 相同
+func (this *HttpClient) SetHostConcurrency(pattern string, n int) *HttpClient {
+	this.rateLimiter().addRule(hostRule{pattern: strings.ToLower(pattern), concurrency: n})
+	return this
+}
+
+// SetAdaptiveRateLimit 设置是否根据 429 响应与 Retry-After 自动降低对应主机的请求速率，默认开启
+// 开启后收到 429 时该主机暂停 Retry-After 指定的时间（未指定时为 1 秒）并将速率减半，之后的正常响应会逐步恢复速率
+// 主机没有通过 SetHostRateLimit 设置速率时，以全局速率为基准降速，未设置全局速率时以每个暂停时间一个请求为基准
+func (this *HttpClient) SetAdaptiveRateLimit(adaptive bool) *HttpClient {
+	l := this.rateLimiter()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.adaptive = adaptive
+	return this
+}
@@ -0,0 +1,105 @@
+package httpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func okTransport() http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})
+}
+
+func TestRateLimiterCancelRefundsToken(t *testing.T) {
+	l := newRateLimiter()
+	l.global = newTokenBucket(1, 1)
+	transport := l.wrap(okTransport())
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := transport.RoundTrip(req.WithContext(ctx)); err == nil {
+		t.Fatal("expected the rate limited request to be cancelled")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 被取消的请求归还令牌后，令牌数只比第一次请求之后多出这段时间内补充的部分
+	if l.global.tokens < -0.5 {
+		t.Errorf("tokens = %v, cancelled request still consumed a token", l.global.tokens)
+	}
+	if s := l.hosts["example.com"]; s == nil || s.active != 0 {
+		t.Errorf("host state %+v, want no active requests", s)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l := newRateLimiter()
+	l.rules = []hostRule{{pattern: "*", rate: 1, burst: 1, concurrency: 1}}
+	now := time.Now()
+
+	busy := l.state("busy.example.com")
+	busy.active = 1
+	paused := l.state("paused.example.com")
+	paused.pausedUntil = now.Add(time.Hour)
+	drained := l.state("drained.example.com")
+	drained.bucket.reserve(now, 1)
+	l.state("idle.example.com")
+
+	l.sweep(time.Now())
+	for _, host := range []string{"busy.example.com", "paused.example.com", "drained.example.com"} {
+		if _, ok := l.hosts[host]; !ok {
+			t.Errorf("%s evicted", host)
+		}
+	}
+	if _, ok := l.hosts["idle.example.com"]; ok {
+		t.Error("idle.example.com not evicted")
+	}
+
+	l.sweep(now.Add(2 * time.Second))
+	if _, ok := l.hosts["drained.example.com"]; ok {
+		t.Error("refilled drained.example.com not evicted")
+	}
+}
+
+func TestRateLimiterAdaptiveWithoutHostRate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(l *rateLimiter)
+	}{
+		{"global rate", func(l *rateLimiter) { l.global = newTokenBucket(10, 10) }},
+		{"concurrency only", func(l *rateLimiter) { l.rules = []hostRule{{pattern: "*", concurrency: 4}} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter()
+			tt.setup(l)
+			_, s, _ := l.wait("example.com")
+			l.done(s)
+			l.observe(s, &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}})
+
+			// 暂停结束后同一时刻的第二个请求需要按降低后的速率等待
+			first, _, _ := l.wait("example.com")
+			second, _, _ := l.wait("example.com")
+			if second <= first || second < 100*time.Millisecond {
+				t.Errorf("waits %v then %v, host not slowed down", first, second)
+			}
+
+			for i := 0; i < 10; i++ {
+				l.observe(s, &http.Response{StatusCode: http.StatusOK, Header: http.Header{}})
+			}
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if s.factor != 1 || s.bucket != nil {
+				t.Errorf("factor %v bucket %v, want full speed restored", s.factor, s.bucket)
+			}
+		})
+	}
+}
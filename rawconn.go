@@ -0,0 +1,200 @@
+package httpc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rawConn 为 rawTransport 使用的一个 HTTP/1.1 连接
+type rawConn struct {
+	net.Conn
+	pool      *rawPool
+	key       string
+	reader    *bufio.Reader
+	writer    *bufio.Writer
+	idleTimer *time.Timer
+	closeOnce sync.Once
+}
+
+// close 关闭连接并归还连接数
+func (this *rawConn) close() {
+	this.closeOnce.Do(func() {
+		_ = this.Conn.Close()
+		this.pool.release(this.key)
+	})
+}
+
+// rawHost 为一个目标地址的连接状态，total 包含空闲连接与使用中的连接
+type rawHost struct {
+	idle    []*rawConn
+	total   int
+	waiters []chan struct{}
+}
+
+// rawPool 为 rawTransport 的连接池，按协议与地址复用连接
+// 空闲连接数量、每个地址的连接数与空闲超时取自 http.Transport 的 MaxIdleConns、MaxIdleConnsPerHost、MaxConnsPerHost 与 IdleConnTimeout
+type rawPool struct {
+	mu    sync.Mutex
+	hosts map[string]*rawHost
+	idle  int
+}
+
+func newRawPool() *rawPool {
+	return &rawPool{hosts: make(map[string]*rawHost)}
+}
+
+// get 返回一个空闲连接或通过 dial 建立新的连接，连接数达到 MaxConnsPerHost 时等待其他连接空闲或关闭
+// 设置 DisableKeepAlives 时关闭已有的空闲连接，reused 表示连接是否为复用的空闲连接
+func (this *rawPool) get(ctx context.Context, key string, transport *http.Transport, dial func() (net.Conn, error)) (conn *rawConn, reused bool, err error) {
+	maxConns := transport.MaxConnsPerHost
+	for {
+		this.mu.Lock()
+		h := this.hosts[key]
+		if h == nil {
+			h = &rawHost{}
+			this.hosts[key] = h
+		}
+		if len(h.idle) > 0 && transport.DisableKeepAlives {
+			stale := h.idle
+			h.idle = nil
+			this.idle -= len(stale)
+			this.mu.Unlock()
+			for _, c := range stale {
+				if c.idleTimer != nil {
+					c.idleTimer.Stop()
+				}
+				c.close()
+			}
+			continue
+		}
+		if n := len(h.idle); n > 0 {
+			conn = h.idle[n-1]
+			h.idle = h.idle[:n-1]
+			this.idle--
+			this.mu.Unlock()
+			if conn.idleTimer != nil {
+				conn.idleTimer.Stop()
+			}
+			return conn, true, nil
+		}
+		if maxConns <= 0 || h.total < maxConns {
+			h.total++
+			this.mu.Unlock()
+			c, err := dial()
+			if err != nil {
+				this.release(key)
+				return nil, false, err
+			}
+			return &rawConn{
+				Conn:   c,
+				pool:   this,
+				key:    key,
+				reader: bufio.NewReader(c),
+				writer: bufio.NewWriter(c),
+			}, false, nil
+		}
+		wait := make(chan struct{})
+		h.waiters = append(h.waiters, wait)
+		this.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			this.mu.Lock()
+			removed := false
+			for i, w := range h.waiters {
+				if w == wait {
+					h.waiters = append(h.waiters[:i], h.waiters[i+1:]...)
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				// 已被唤醒但不再使用，将机会交给下一个等待者
+				h.wake()
+			}
+			this.cleanup(key, h)
+			this.mu.Unlock()
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// put 将连接放回空闲队列，超过空闲连接上限时关闭连接
+func (this *rawPool) put(conn *rawConn, transport *http.Transport) {
+	maxPerHost := transport.MaxIdleConnsPerHost
+	if maxPerHost == 0 {
+		maxPerHost = http.DefaultMaxIdleConnsPerHost
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	this.mu.Lock()
+	h := this.hosts[conn.key]
+	if transport.DisableKeepAlives || h == nil || len(h.idle) >= maxPerHost || (transport.MaxIdleConns > 0 && this.idle >= transport.MaxIdleConns) {
+		this.mu.Unlock()
+		conn.close()
+		return
+	}
+	h.idle = append(h.idle, conn)
+	this.idle++
+	if transport.IdleConnTimeout > 0 {
+		conn.idleTimer = time.AfterFunc(transport.IdleConnTimeout, func() {
+			this.expire(conn)
+		})
+	}
+	h.wake()
+	this.mu.Unlock()
+}
+
+// expire 关闭超过空闲时间的连接，连接已被取出时不做处理
+func (this *rawPool) expire(conn *rawConn) {
+	this.mu.Lock()
+	h := this.hosts[conn.key]
+	found := false
+	if h != nil {
+		for i, c := range h.idle {
+			if c == conn {
+				h.idle = append(h.idle[:i], h.idle[i+1:]...)
+				this.idle--
+				found = true
+				break
+			}
+		}
+	}
+	this.mu.Unlock()
+	if found {
+		conn.close()
+	}
+}
+
+// release 在连接关闭后减少连接数并唤醒一个等待者
+func (this *rawPool) release(key string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	h := this.hosts[key]
+	if h == nil {
+		return
+	}
+	h.total--
+	h.wake()
+	this.cleanup(key, h)
+}
+
+// cleanup 删除没有连接与等待者的地址，调用者需持有锁
+func (this *rawPool) cleanup(key string, h *rawHost) {
+	if h.total <= 0 && len(h.idle) == 0 && len(h.waiters) == 0 {
+		delete(this.hosts, key)
+	}
+}
+
+// wake 唤醒第一个等待者，调用者需持有锁
+func (h *rawHost) wake() {
+	if len(h.waiters) > 0 {
+		close(h.waiters[0])
+		h.waiters = h.waiters[1:]
+	}
+}
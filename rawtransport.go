@@ -0,0 +1,432 @@
+package httpc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRawProxy 在开启请求头顺序或大小写保留时使用代理返回
+var ErrRawProxy = errors.New("httpc: header order and case preservation are not supported through a proxy")
+
+// defaultUserAgent 与 net/http 在未设置 User-Agent 时发送的值一致
+const defaultUserAgent = "Go-http-client/1.1"
+
+// rawTransport 直接按 HTTP/1.1 格式写出请求，请求头按 Request 上的添加顺序发送，可选保留名称的大小写
+// 连接通过 rawPool 复用，拨号、TLS、超时与连接数配置取自 http.Transport，不支持 HTTP/2 与代理
+type rawTransport struct {
+	transport    *http.Transport
+	pool         *rawPool
+	preserveCase bool
+}
+
+// SetHeaderOrder 设置是否按照 SetHeader、AddHeader 的调用顺序发送请求头，默认关闭
+// net/http 总是按名称排序发送请求头，开启后请求改为由内置的 HTTP/1.1 实现发送：
+// 连接会被复用并遵循 http.Transport 的超时、拨号与连接数配置，但不支持 HTTP/2，且不能与 SetProxy 同时使用，使用代理时请求返回 ErrRawProxy
+// 未通过 Request 设置的请求头（例如 CookieJar 添加的 Cookie）按名称排序排在之后
+func (this *HttpClient) SetHeaderOrder(b bool) *HttpClient {
+	this.headerOrder = b
+	return this
+}
+
+// SetPreserveHeaderCase 设置是否按调用者传入的大小写发送请求头名称，例如 "x-api-key" 不会被转为 "X-Api-Key"
+// 开启后同时按调用顺序发送请求头，限制与 SetHeaderOrder 相同
+func (this *HttpClient) SetPreserveHeaderCase(b bool) *HttpClient {
+	this.headerCase = b
+	return this
+}
+
+func (this *rawTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	closeBody := func() {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		closeBody()
+		return nil, errors.New("httpc: unsupported protocol scheme " + req.URL.Scheme)
+	}
+	if this.transport.Proxy != nil {
+		proxy, err := this.transport.Proxy(req)
+		if err != nil || proxy != nil {
+			closeBody()
+			if err == nil {
+				err = ErrRawProxy
+			}
+			return nil, err
+		}
+	}
+
+	ctx := req.Context()
+	addr := rawAddr(req.URL)
+	for {
+		conn, reused, err := this.pool.get(ctx, req.URL.Scheme+"://"+addr, this.transport, func() (net.Conn, error) {
+			return this.dial(ctx, req.URL.Scheme, addr, req.URL.Hostname())
+		})
+		if err != nil {
+			closeBody()
+			return nil, err
+		}
+		resp, retry, err := this.roundTrip(ctx, conn, req)
+		if err == nil {
+			return resp, nil
+		}
+		// 复用的空闲连接可能已被服务端关闭，尚未收到响应时使用新的请求体重试
+		if !reused || !retry || ctx.Err() != nil {
+			return nil, err
+		}
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// roundTrip 在 conn 上发送请求并读取响应头
+// retry 表示请求失败时尚未收到任何响应数据，且请求可以安全地重新发送
+func (this *rawTransport) roundTrip(ctx context.Context, conn *rawConn, req *http.Request) (resp *http.Response, retry bool, err error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	var bodyOnce sync.Once
+	closeBody := func() {
+		bodyOnce.Do(func() {
+			if hasBody {
+				_ = req.Body.Close()
+			}
+		})
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Conn.Close()
+	})
+	fail := func(err error, retry bool) (*http.Response, bool, error) {
+		stop()
+		closeBody()
+		conn.close()
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		return nil, retry && replayable(req), err
+	}
+
+	chunked := hasBody && (req.ContentLength <= 0 || slices.Contains(req.TransferEncoding, "chunked"))
+	expect := hasBody && this.transport.ExpectContinueTimeout > 0 && headerHasToken(req.Header, "Expect", "100-continue")
+	if err = this.writeHeader(conn.writer, req, hasBody, chunked); err != nil {
+		return fail(err, false)
+	}
+	bodySent := !hasBody
+	sendBody := func() error {
+		bodySent = true
+		err := writeBody(conn.writer, req, chunked)
+		closeBody()
+		if err != nil {
+			return err
+		}
+		return conn.writer.Flush()
+	}
+	if expect {
+		// 发送请求头后等待 100 Continue，超时未收到响应时直接发送请求体
+		if err = conn.writer.Flush(); err != nil {
+			return fail(err, true)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(this.transport.ExpectContinueTimeout))
+		if _, err = conn.reader.Peek(1); err != nil {
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				return fail(err, true)
+			}
+			if err = sendBody(); err != nil {
+				return fail(err, false)
+			}
+		}
+	} else if hasBody {
+		if err = sendBody(); err != nil {
+			return fail(err, false)
+		}
+	} else if err = conn.writer.Flush(); err != nil {
+		return fail(err, true)
+	}
+
+	if this.transport.ResponseHeaderTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(this.transport.ResponseHeaderTimeout))
+	} else {
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+	if _, err = conn.reader.Peek(1); err != nil {
+		return fail(err, true)
+	}
+	for {
+		resp, err = http.ReadResponse(conn.reader, req)
+		if err != nil {
+			return fail(err, false)
+		}
+		if resp.StatusCode == http.StatusContinue {
+			if !bodySent {
+				if err = sendBody(); err != nil {
+					return fail(err, false)
+				}
+			}
+			continue
+		}
+		if resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+			break
+		}
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	// 服务端未等待请求体直接返回最终响应时，连接上的状态不再确定，不再复用
+	keepAlive := bodySent && !resp.Close && !req.Close && !this.transport.DisableKeepAlives &&
+		resp.StatusCode != http.StatusSwitchingProtocols && !headerHasToken(req.Header, "Connection", "close")
+	closeBody()
+	resp.Body = &connBody{
+		ReadCloser: resp.Body,
+		conn:       conn,
+		stop:       stop,
+		transport:  this.transport,
+		keepAlive:  keepAlive,
+		eof:        resp.Body == http.NoBody,
+	}
+	return resp, false, nil
+}
+
+// rawAddr 返回 URL 对应的 host:port
+func rawAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// dial 建立到目标地址的连接，https 时优先使用 http.Transport.DialTLSContext，
+// 否则在 TLSHandshakeTimeout 内完成 TLS 握手并只协商 HTTP/1.1
+func (this *rawTransport) dial(ctx context.Context, scheme, addr, host string) (net.Conn, error) {
+	if scheme == "https" && this.transport.DialTLSContext != nil {
+		return this.transport.DialTLSContext(ctx, "tcp", addr)
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if this.transport.DialContext != nil {
+		conn, err = this.transport.DialContext(ctx, "tcp", addr)
+	} else {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil || scheme != "https" {
+		return conn, err
+	}
+
+	config := &tls.Config{}
+	if this.transport.TLSClientConfig != nil {
+		config = this.transport.TLSClientConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	config.NextProtos = []string{"http/1.1"}
+	handshakeCtx := ctx
+	if this.transport.TLSHandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		handshakeCtx, cancel = context.WithTimeout(ctx, this.transport.TLSHandshakeTimeout)
+		defer cancel()
+	}
+	tlsConn := tls.Client(conn, config)
+	if err = tlsConn.HandshakeContext(handshakeCtx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// writeHeader 写出请求行与请求头，不刷新缓冲
+func (this *rawTransport) writeHeader(writer *bufio.Writer, req *http.Request, hasBody, chunked bool) error {
+	if _, err := fmt.Fprintf(writer, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI()); err != nil {
+		return err
+	}
+	for _, f := range this.orderedFields(req, hasBody, chunked) {
+		if strings.ContainsAny(f.Name, "\r\n: ") || strings.ContainsAny(f.Value, "\r\n") {
+			return fmt.Errorf("httpc: invalid header field %q", f.Name)
+		}
+		if _, err := writer.WriteString(f.Name + ": " + f.Value + "\r\n"); err != nil {
+			return err
+		}
+	}
+	_, err := writer.WriteString("\r\n")
+	return err
+}
+
+// writeBody 写出请求体，不刷新缓冲
+func writeBody(writer *bufio.Writer, req *http.Request, chunked bool) error {
+	if chunked {
+		cw := httputil.NewChunkedWriter(writer)
+		if _, err := io.Copy(cw, req.Body); err != nil {
+			return err
+		}
+		if err := cw.Close(); err != nil {
+			return err
+		}
+		_, err := writer.WriteString("\r\n")
+		return err
+	}
+	n, err := io.Copy(writer, io.LimitReader(req.Body, req.ContentLength+1))
+	if err != nil {
+		return err
+	}
+	if n != req.ContentLength {
+		return fmt.Errorf("httpc: request body length %d does not match Content-Length %d", n, req.ContentLength)
+	}
+	return nil
+}
+
+// replayable 判断请求能否在新的连接上重新发送：方法为幂等方法且请求体可以重新获取
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+	default:
+		if _, ok := req.Header["Idempotency-Key"]; !ok {
+			return false
+		}
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest 返回使用新请求体的请求副本
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	newBody, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := *req
+	r.Body = newBody
+	return &r, nil
+}
+
+// headerHasToken 判断请求头中是否包含以逗号分隔的 token，不区分大小写
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header[name] {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// orderedFields 按 Request 上的添加顺序排列请求头
+// 未出现在顺序中的请求头按名称排序排在之后，Host 未指定位置时排在最前
+func (this *rawTransport) orderedFields(req *http.Request, hasBody, chunked bool) []headerField {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	special := map[string]string{"Host": host}
+	switch {
+	case chunked:
+		special["Transfer-Encoding"] = "chunked"
+	case hasBody || req.ContentLength > 0 || req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch:
+		special["Content-Length"] = strconv.FormatInt(max(req.ContentLength, 0), 10)
+	}
+
+	var fields []headerField
+	used := make(map[string]int)
+	order, _ := req.Context().Value(headerOrderKey{}).([]string)
+	for _, name := range order {
+		key := textproto.CanonicalMIMEHeaderKey(name)
+		if !this.preserveCase {
+			name = key
+		}
+		if value, ok := special[key]; ok {
+			if used[key] == 0 {
+				fields = append(fields, headerField{Name: name, Value: value})
+				used[key]++
+			}
+			continue
+		}
+		if values := req.Header[key]; used[key] < len(values) {
+			fields = append(fields, headerField{Name: name, Value: values[used[key]]})
+			used[key]++
+		}
+	}
+	if used["Host"] == 0 {
+		fields = append([]headerField{{Name: "Host", Value: host}}, fields...)
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		if _, ok := special[textproto.CanonicalMIMEHeaderKey(key)]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range req.Header[key][used[key]:] {
+			fields = append(fields, headerField{Name: key, Value: value})
+		}
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		fields = append(fields, headerField{Name: "User-Agent", Value: defaultUserAgent})
+	}
+	if _, ok := req.Header["Connection"]; !ok && this.transport.DisableKeepAlives {
+		fields = append(fields, headerField{Name: "Connection", Value: "close"})
+	}
+	for _, key := range []string{"Content-Length", "Transfer-Encoding"} {
+		if value, ok := special[key]; ok && used[key] == 0 {
+			fields = append(fields, headerField{Name: key, Value: value})
+		}
+	}
+	return fields
+}
+
+// connBody 在响应体读取完毕并关闭时将连接放回连接池，未读取完毕或连接不可复用时关闭连接
+type connBody struct {
+	io.ReadCloser
+	conn      *rawConn
+	stop      func() bool
+	transport *http.Transport
+	keepAlive bool
+	eof       bool
+	closed    bool
+}
+
+func (this *connBody) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	if err == io.EOF {
+		this.eof = true
+	}
+	return n, err
+}
+
+func (this *connBody) Close() error {
+	if this.closed {
+		return nil
+	}
+	this.closed = true
+	err := this.ReadCloser.Close()
+	if this.stop() && this.keepAlive && this.eof {
+		this.conn.pool.put(this.conn, this.transport)
+	} else {
+		this.conn.close()
+	}
+	return err
+}
@@ -0,0 +1,255 @@
+package httpc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Albert-Zhan/httpc/body"
+)
+
+// rawServer 记录收到的原始请求字节，并对每个请求返回 200 ok
+type rawServer struct {
+	listener net.Listener
+	accepted atomic.Int32
+	mu       sync.Mutex
+	requests []string
+	// handle 在读取请求头之后、读取请求体之前调用
+	handle func(conn net.Conn, br *bufio.Reader)
+	// closeIdle 为 true 时每个响应之后关闭连接，但不发送 Connection: close
+	closeIdle bool
+}
+
+func newRawServer(t *testing.T) *rawServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &rawServer{listener: l}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.accepted.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *rawServer) url(path string) string {
+	return "http://" + s.listener.Addr().String() + path
+}
+
+func (s *rawServer) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	var raw bytes.Buffer
+	br := bufio.NewReader(io.TeeReader(conn, &raw))
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		if s.handle != nil {
+			s.handle(conn, br)
+		}
+		if _, err = io.Copy(io.Discard, req.Body); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, raw.String()[:raw.Len()-br.Buffered()])
+		s.mu.Unlock()
+		raw.Next(raw.Len() - br.Buffered())
+		if _, err = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"); err != nil || s.closeIdle {
+			return
+		}
+	}
+}
+
+func (s *rawServer) last() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return ""
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+func rawBody(data string) *body.Raw {
+	b := body.NewRawData()
+	b.SetData(data, body.Text)
+	return b
+}
+
+func TestRawTransportWire(t *testing.T) {
+	s := newRawServer(t)
+	host := s.listener.Addr().String()
+	tests := []struct {
+		name  string
+		setup func(client *HttpClient) *Request
+		want  string
+	}{
+		{
+			name: "preserve case and order",
+			setup: func(client *HttpClient) *Request {
+				client.SetPreserveHeaderCase(true)
+				return NewRequest(client).SetMethod("POST").SetUrl(s.url("/a?x=1")).
+					SetHeader("x-api-key", "k").
+					AddHeader("accept", "text/html").
+					AddHeader("ACCEPT", "*/*").
+					SetHeader("user-agent", "test").
+					SetBody(rawBody("hello"))
+			},
+			want: "POST /a?x=1 HTTP/1.1\r\n" +
+				"Host: " + host + "\r\n" +
+				"x-api-key: k\r\n" +
+				"accept: text/html\r\n" +
+				"ACCEPT: */*\r\n" +
+				"user-agent: test\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"Content-Length: 5\r\n" +
+				"\r\n" +
+				"hello",
+		},
+		{
+			name: "canonical order with positioned host and length",
+			setup: func(client *HttpClient) *Request {
+				client.SetHeaderOrder(true)
+				return NewRequest(client).SetMethod("POST").SetUrl(s.url("/b")).
+					SetHeader("x-b", "2").
+					SetContentLength(3).
+					SetHeader("host", "example.test").
+					SetHeader("x-a", "1").
+					SetBody(rawBody("abc"))
+			},
+			want: "POST /b HTTP/1.1\r\n" +
+				"X-B: 2\r\n" +
+				"Content-Length: 3\r\n" +
+				"Host: example.test\r\n" +
+				"X-A: 1\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"User-Agent: Go-http-client/1.1\r\n" +
+				"\r\n" +
+				"abc",
+		},
+		{
+			name: "chunked body",
+			setup: func(client *HttpClient) *Request {
+				client.SetPreserveHeaderCase(true)
+				return NewRequest(client).SetMethod("PUT").SetUrl(s.url("/c")).
+					SetHeader("x-first", "1").
+					SetChunked(true).
+					SetBody(rawBody("hello"))
+			},
+			want: "PUT /c HTTP/1.1\r\n" +
+				"Host: " + host + "\r\n" +
+				"x-first: 1\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"User-Agent: Go-http-client/1.1\r\n" +
+				"\r\n" +
+				"5\r\nhello\r\n0\r\n\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data, err := tt.setup(NewHttpClient()).Send().EndByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK || string(data) != "ok" {
+				t.Fatalf("got %d %q", resp.StatusCode, data)
+			}
+			if got := s.last(); got != tt.want {
+				t.Errorf("wire mismatch\ngot:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawTransportReuse(t *testing.T) {
+	s := newRawServer(t)
+	client := NewHttpClient().SetHeaderOrder(true)
+	for i := 0; i < 3; i++ {
+		if _, _, err := NewRequest(client).SetUrl(s.url("/")).Send().EndByte(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.accepted.Load(); n != 1 {
+		t.Errorf("accepted %d connections, want 1", n)
+	}
+
+	client.CustomizeTransport(func(tr *http.Transport) {
+		tr.DisableKeepAlives = true
+	})
+	for i := 0; i < 2; i++ {
+		if _, _, err := NewRequest(client).SetUrl(s.url("/")).Send().EndByte(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.accepted.Load(); n != 3 {
+		t.Errorf("accepted %d connections, want 3", n)
+	}
+	if got := s.last(); !bytes.Contains([]byte(got), []byte("Connection: close\r\n")) {
+		t.Errorf("missing Connection: close in %q", got)
+	}
+}
+
+func TestRawTransportRetryClosedIdle(t *testing.T) {
+	s := newRawServer(t)
+	s.closeIdle = true
+	client := NewHttpClient().SetHeaderOrder(true)
+	for i := 0; i < 3; i++ {
+		if _, _, err := NewRequest(client).SetUrl(s.url("/")).Send().EndByte(); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := s.accepted.Load(); n != 3 {
+		t.Errorf("accepted %d connections, want 3", n)
+	}
+}
+
+func TestRawTransportExpectContinue(t *testing.T) {
+	s := newRawServer(t)
+	early := make(chan bool, 1)
+	s.handle = func(conn net.Conn, br *bufio.Reader) {
+		// 在发送 100 Continue 之前不应收到请求体
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := br.Peek(1)
+		_ = conn.SetReadDeadline(time.Time{})
+		early <- err == nil
+		_, _ = io.WriteString(conn, "HTTP/1.1 100 Continue\r\n\r\n")
+	}
+	client := NewHttpClient().SetHeaderOrder(true)
+	resp, _, err := NewRequest(client).SetMethod("POST").SetUrl(s.url("/")).
+		SetExpectContinue(true).
+		SetBody(rawBody("hello")).
+		Send().EndByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if <-early {
+		t.Error("body sent before 100 Continue")
+	}
+	if got := s.last(); !bytes.HasSuffix([]byte(got), []byte("\r\n\r\nhello")) {
+		t.Errorf("body not received: %q", got)
+	}
+}
@@ -1,8 +1,6 @@
 package httpc
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"errors"
@@ -11,40 +9,64 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// pathParamPattern 匹配 URL 路径模板中的 {name}
+var pathParamPattern = regexp.MustCompile(`\{([A-Za-z0-9_.-]+)\}`)
+
 // Request 封装了 HTTP 请求构建和发送的逻辑
 type Request struct {
-	httpc    *HttpClient
-	request  *http.Request
-	response *http.Response
-	method   string
-	url      string
-	param    *url.Values
-	header   map[string]string
-	cookies  *[]*http.Cookie
-	data     body.Body
-	debug    bool
-	err      error
+	httpc              *HttpClient
+	session            *Session
+	request            *http.Request
+	response           *http.Response
+	method             string
+	url                string
+	param              *url.Values
+	baseParam          url.Values
+	pathParams         map[string]string
+	header             headerList
+	cookies            *[]*http.Cookie
+	data               body.Body
+	retry              *RetryPolicy
+	middlewares        []Middleware
+	trace              *requestTrace
+	start              time.Time
+	charset            string
+	rawCharset         bool
+	resume             *resumeState
+	collision          FileCollision
+	extFromType        bool
+	checksumAlgorithm  string
+	checksumExpected   string
+	checksumFromHeader bool
+	uploadProgress     ProgressFunc
+	downloadProgress   ProgressFunc
+	progressInterval   time.Duration
+	encoded            sizedBody
+	debug              bool
+	err                error
 }
 
 // NewRequest 创建一个新的 Request 对象，默认使用 GET 方法
 func NewRequest(client *HttpClient) *Request {
 	return &Request{
-		httpc:   client,
-		method:  "GET",
-		param:   &url.Values{},
-		header:  make(map[string]string),
-		cookies: new([]*http.Cookie),
-		debug:   false,
-		err:     nil,
+		httpc:            client,
+		method:           "GET",
+		param:            &url.Values{},
+		cookies:          new([]*http.Cookie),
+		debug:            false,
+		err:              nil,
+		progressInterval: defaultProgressInterval,
 	}
 }
 
-// SetClient 替换请求使用的 HttpClient
+// SetClient 替换请求使用的 HttpClient，发送时使用新 HttpClient 的基础 URL 与配置
 func (this *Request) SetClient(client *HttpClient) *Request {
 	this.httpc = client
 	return this
@@ -57,6 +79,8 @@ func (this *Request) SetMethod(name string) *Request {
 }
 
 // SetUrl 设置请求的URL地址
+// HttpClient 或 Session 设置了基础 URL 时，可以传入相对地址，例如 "/users/{id}"，发送时拼接在基础 URL 之后
+// 地址中的 {name} 会被 SetPathParam 设置的值替换
 func (this *Request) SetUrl(url string) *Request {
 	this.url = url
 	return this
@@ -68,17 +92,75 @@ func (this *Request) SetParam(name, value string) *Request {
 	return this
 }
 
-// SetHeader 添加或修改请求头，支持多次链式调用
+// SetPathParam 设置 URL 路径模板中 {name} 对应的值，值会经过路径转义
+// 例如 SetUrl("/users/{id}").SetPathParam("id", "1")，发送时路径中仍有未设置的参数会返回错误
+func (this *Request) SetPathParam(name, value string) *Request {
+	if this.pathParams == nil {
+		this.pathParams = make(map[string]string)
+	}
+	this.pathParams[name] = value
+	return this
+}
+
+// SetPathParams 批量设置 URL 路径模板参数
+func (this *Request) SetPathParams(params map[string]string) *Request {
+	for k, v := range params {
+		this.SetPathParam(k, v)
+	}
+	return this
+}
+
+// SetHeader 设置请求头，已存在同名请求头时在原位置替换其值，否则追加到末尾，名称不区分大小写
+// 请求头按添加顺序保存，配合 HttpClient.SetHeaderOrder 可按该顺序发送，支持多次链式调用
+// Host、Content-Length 与 Transfer-Encoding 会写入 http.Request 对应的字段，Transfer-Encoding 只支持 chunked，
+// 无法发送的请求头（例如 Trailer）会在发送时返回错误
 func (this *Request) SetHeader(name, value string) *Request {
-	this.header[name] = value
+	this.header = this.header.set(name, value)
 	return this
 }
 
+// AddHeader 追加一个请求头，同名请求头可以添加多次
+func (this *Request) AddHeader(name, value string) *Request {
+	this.header = this.header.add(name, value)
+	return this
+}
+
+// DelHeader 删除全部同名请求头
+func (this *Request) DelHeader(name string) *Request {
+	this.header = this.header.del(name)
+	return this
+}
+
+// SetContentLength 设置请求体长度，未设置时根据请求体自动计算，长度与请求体不一致时发送会失败
+// 等同于 SetHeader("Content-Length", n)
+func (this *Request) SetContentLength(n int64) *Request {
+	return this.SetHeader("Content-Length", strconv.FormatInt(n, 10))
+}
+
+// SetChunked 设置是否以 chunked 编码发送请求体，不发送 Content-Length
+// 等同于 SetHeader("Transfer-Encoding", "chunked")
+func (this *Request) SetChunked(b bool) *Request {
+	if b {
+		return this.SetHeader("Transfer-Encoding", "chunked")
+	}
+	return this.DelHeader("Transfer-Encoding")
+}
+
+// SetExpectContinue 设置是否发送 "Expect: 100-continue"，服务端返回 100 Continue 后才发送请求体，
+// 适用于上传大文件前由服务端先校验请求头的场景，等待时间由 http.Transport.ExpectContinueTimeout 控制，默认 1s，
+// 超时未收到响应时直接发送请求体，服务端直接返回最终响应时不发送请求体；开启 SetHeaderOrder 或 SetPreserveHeaderCase 时同样适用
+func (this *Request) SetExpectContinue(b bool) *Request {
+	if b {
+		return this.SetHeader("Expect", "100-continue")
+	}
+	return this.DelHeader("Expect")
+}
+
 // SetBasicAuth 设置 HTTP Basic Auth 认证
 func (this *Request) SetBasicAuth(username, password string) *Request {
 	auth := username + ":" + password
 	value := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
-	this.header["Authorization"] = value
+	this.header = this.header.set("Authorization", value)
 	return this
 }
 
@@ -95,6 +177,18 @@ func (this *Request) SetDebug(d bool) *Request {
 	return this
 }
 
+// SetRetry 设置当前请求的重试策略，优先于 HttpClient 上设置的策略
+func (this *Request) SetRetry(p *RetryPolicy) *Request {
+	this.retry = p
+	return this
+}
+
+// Use 为当前请求注册中间件，在客户端中间件之后执行
+func (this *Request) Use(middlewares ...Middleware) *Request {
+	this.middlewares = append(this.middlewares, middlewares...)
+	return this
+}
+
 // SetBody 设置请求体，实现 body.Body 接口
 func (this *Request) SetBody(body body.Body) *Request {
 	this.data = body
@@ -103,49 +197,305 @@ func (this *Request) SetBody(body body.Body) *Request {
 
 // Send 构建并发送 HTTP 请求
 // 可选传入 context，用于控制请求超时或取消
+// 若设置了重试策略，失败时会按策略重新构建请求体并重试
 func (this *Request) Send(ctxs ...context.Context) *Request {
-	param := this.param.Encode()
-	if param != "" {
-		this.url += "?" + param
+	this.start = time.Now()
+	this.request, this.response, this.err = nil, nil, nil
+
+	ctx := context.Background()
+	if len(ctxs) > 0 {
+		ctx = ctxs[0]
+	}
+
+	policy := this.retry
+	if policy == nil {
+		policy = this.httpc.retry
+	}
+
+	for attempt := 1; ; attempt++ {
+		this.trace = &requestTrace{}
+		this.request, this.err = this.newRequest(this.trace.withContext(ctx))
+		if this.err != nil {
+			return this
+		}
+
+		this.log()
+
+		this.response, this.err = this.httpc.do(this.request, this.middlewares)
+		if policy == nil {
+			return this
+		}
+
+		retry := attempt < policy.maxAttempts && ctx.Err() == nil && policy.shouldRetry(this.request, this.response, this.err)
+		var wait time.Duration
+		if retry {
+			wait = policy.backoff(attempt, this.response)
+		}
+		if policy.hook != nil {
+			policy.hook(RetryAttempt{
+				Attempt:  attempt,
+				Request:  this.request,
+				Response: this.response,
+				Err:      this.err,
+				Retry:    retry,
+				Wait:     wait,
+			})
+		}
+		if !retry {
+			return this
+		}
+
+		drainBody(this.response)
+		this.response = nil
+		if err := sleepContext(ctx, wait); err != nil {
+			this.err = err
+			return this
+		}
 	}
+}
+
+// sizedBody 为 body.BodyV2 的 Open 返回的内存中请求体内容
+type sizedBody interface {
+	io.ReaderAt
+	Size() int64
+}
 
-	var data io.Reader
-	contentType := ""
+// newRequest 根据当前配置构建一个新的 http.Request
+// 每次调用都会重新编码请求体，以便重试时可以再次发送
+func (this *Request) newRequest(ctx context.Context) (*http.Request, error) {
+	var (
+		data        io.Reader
+		length      int64
+		contentType string
+	)
+	this.encoded = nil
 
-	if this.data != nil {
+	var getBody func() (io.ReadCloser, error)
+	v2, isV2 := this.data.(body.BodyV2)
+	if isV2 {
+		rc, err := v2.Open()
+		if err != nil {
+			return nil, errors.New("encode body failed:" + err.Error())
+		}
+		data, getBody = rc, v2.Open
+		if sized, ok := rc.(sizedBody); ok {
+			// 内存中的请求体以本次编码的内容计算长度并重新读取，避免多次编码的结果不一致
+			this.encoded, length = sized, sized.Size()
+			getBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(sized, 0, length)), nil
+			}
+		} else {
+			length = v2.Length()
+		}
+		contentType = v2.GetContentType()
+	} else if this.data != nil {
 		data = this.data.Encode()
+		if e, ok := this.data.(body.Errorer); ok && e.Err() != nil {
+			return nil, errors.New("encode body failed:" + e.Err().Error())
+		}
 		contentType = this.data.GetContentType()
 	}
 
-	ctx := context.Background()
-	if len(ctxs) > 0 {
-		ctx = ctxs[0]
+	rawUrl, err := this.requestUrl()
+	if err != nil {
+		if rc, ok := data.(io.Closer); ok && isV2 {
+			_ = rc.Close()
+		}
+		return nil, err
+	}
+	if len(this.header) > 0 {
+		ctx = context.WithValue(ctx, headerOrderKey{}, this.header.names())
+	}
+	request, err := http.NewRequestWithContext(ctx, this.method, rawUrl, data)
+	if err != nil {
+		if rc, ok := data.(io.Closer); ok && isV2 {
+			_ = rc.Close()
+		}
+		return nil, err
 	}
 
-	this.request, this.err = http.NewRequestWithContext(ctx, this.method, this.url, data)
-	if this.err != nil {
-		return this
+	if isV2 {
+		request.GetBody = getBody
+		request.ContentLength = length
+		if length == 0 {
+			_ = request.Body.Close()
+			request.Body = http.NoBody
+		}
 	}
 
 	if contentType != "" {
-		this.request.Header.Set("Content-Type", contentType)
+		request.Header.Set("Content-Type", contentType)
+	}
+	if err = this.header.applyHeaders(request); err != nil {
+		if request.Body != nil {
+			_ = request.Body.Close()
+		}
+		return nil, err
+	}
+	if this.resume != nil {
+		request.Header.Set("Accept-Encoding", "identity")
+		if this.resume.offset > 0 {
+			request.Header.Set("Range", fmt.Sprintf("bytes=%d-", this.resume.offset))
+			request.Header.Set("If-Range", this.resume.validator)
+		}
+	}
+	for _, v := range *this.cookies {
+		request.AddCookie(v)
 	}
-	for k, v := range this.header {
-		this.request.Header.Set(k, v)
+	this.wrapUpload(request)
+	return request, nil
+}
+
+// baseUrl 返回发送时使用的基础 URL，优先使用会话的基础 URL，其次为当前 HttpClient 的基础 URL
+func (this *Request) baseUrl() string {
+	if this.session != nil {
+		this.session.mu.RLock()
+		base := this.session.baseUrl
+		this.session.mu.RUnlock()
+		if base != "" {
+			return base
+		}
+	}
+	return this.httpc.baseUrl
+}
+
+// requestUrl 返回拼接基础 URL、替换路径参数并合并查询参数后的请求地址，不会修改 Request 中保存的 URL
+// SetParam 添加的参数追加在 URL 原有的查询参数之后，与会话默认参数同名时以 SetParam 的为准
+func (this *Request) requestUrl() (string, error) {
+	rawUrl, err := expandPath(joinUrl(this.baseUrl(), this.url), this.pathParams)
+	if err != nil {
+		return "", err
+	}
+	params := *this.param
+	if len(this.baseParam) > 0 {
+		params = url.Values{}
+		for k, v := range this.baseParam {
+			params[k] = v
+		}
+		for k, v := range *this.param {
+			params[k] = v
+		}
+	}
+	param := params.Encode()
+	if param == "" {
+		return rawUrl, nil
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl, nil
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&" + param
+	} else {
+		u.RawQuery = param
+	}
+	return u.String(), nil
+}
+
+// Clone 返回当前请求的副本，可将配置好的 Request 作为模板在多个协程中复制后分别发送
+// 头信息、查询参数、Cookie 与中间件列表会被深拷贝，请求体实现 body.Cloner 时同样会被复制，
+// 重试策略与 HttpClient 在副本之间共享，上一次发送得到的响应与错误不会被复制
+func (this *Request) Clone() *Request {
+	c := *this
+	c.request, c.response, c.trace, c.resume, c.encoded, c.err = nil, nil, nil, nil, nil, nil
+
+	param := url.Values{}
+	for k, v := range *this.param {
+		param[k] = append([]string(nil), v...)
 	}
+	c.param = &param
+
+	c.header = this.header.clone()
+
+	cookies := make([]*http.Cookie, 0, len(*this.cookies))
 	for _, v := range *this.cookies {
-		this.request.AddCookie(v)
+		cookie := *v
+		cookies = append(cookies, &cookie)
 	}
+	c.cookies = &cookies
 
-	this.log()
+	if this.pathParams != nil {
+		c.pathParams = make(map[string]string, len(this.pathParams))
+		for k, v := range this.pathParams {
+			c.pathParams[k] = v
+		}
+	}
 
-	this.response, this.err = this.httpc.client.Do(this.request)
-	if this.err != nil {
-		return this
+	c.middlewares = append([]Middleware(nil), this.middlewares...)
+	if cloner, ok := this.data.(body.Cloner); ok {
+		c.data = cloner.Clone()
+	}
+	return &c
+}
+
+// Reset 清空请求的全部配置与上一次发送的结果，恢复为 NewRequest 创建时的状态，使用的 HttpClient 保持不变
+// 由 Session 创建的请求会恢复为继承会话当前默认值的状态
+func (this *Request) Reset() *Request {
+	if this.session != nil {
+		*this = *this.session.NewRequest()
+	} else {
+		*this = *NewRequest(this.httpc)
 	}
 	return this
 }
 
+// expandPath 将 URL 路径中的 {name} 替换为转义后的参数值，查询参数与片段中的内容不会被替换
+// 路径中存在未设置的参数时返回错误
+func expandPath(rawUrl string, params map[string]string) (string, error) {
+	end := strings.IndexAny(rawUrl, "?#")
+	if end < 0 {
+		end = len(rawUrl)
+	}
+	var missing []string
+	path := pathParamPattern.ReplaceAllStringFunc(rawUrl[:end], func(m string) string {
+		name := m[1 : len(m)-1]
+		value, ok := params[name]
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		return url.PathEscape(value)
+	})
+	if len(missing) > 0 {
+		return "", errors.New("missing path parameter: " + strings.Join(missing, ", "))
+	}
+	return path + rawUrl[end:], nil
+}
+
+// joinUrl 将相对地址拼接在基础 URL 之后，保留基础 URL 的路径与查询参数，片段取自 rawUrl
+// rawUrl 为带协议的绝对地址或 base 为空时直接返回 rawUrl
+func joinUrl(base, rawUrl string) string {
+	if base == "" {
+		return rawUrl
+	}
+	if u, err := url.Parse(rawUrl); err == nil && u.IsAbs() {
+		return rawUrl
+	}
+	if rawUrl == "" {
+		return base
+	}
+	base, _, _ = strings.Cut(base, "#")
+	rawUrl, fragment, hasFragment := strings.Cut(rawUrl, "#")
+	base, baseQuery, _ := strings.Cut(base, "?")
+	path, query, _ := strings.Cut(rawUrl, "?")
+	joined := strings.TrimRight(base, "/")
+	if path != "" {
+		joined += "/" + strings.TrimLeft(path, "/")
+	}
+	if baseQuery != "" && query != "" {
+		query = baseQuery + "&" + query
+	} else if baseQuery != "" {
+		query = baseQuery
+	}
+	if query != "" {
+		joined += "?" + query
+	}
+	if hasFragment {
+		joined += "#" + fragment
+	}
+	return joined
+}
+
 // GetResponse 返回 HTTP 响应对象
 func (this *Request) GetResponse() *http.Response {
 	return this.response
@@ -160,28 +510,37 @@ func (this *Request) GetError() error {
 func (this *Request) log() {
 	if this.debug == true {
 		data := ""
-		if this.data != nil {
+		if this.encoded != nil {
+			buf := make([]byte, this.encoded.Size())
+			n, _ := this.encoded.ReadAt(buf, 0)
+			data = string(buf[:n])
+		} else if this.data != nil {
 			data = this.data.GetData()
 		}
 		fmt.Printf("[httpc Debug]\n")
 		fmt.Printf("-------------------------------------------------------------------\n")
-		fmt.Printf("Request: %s %s\nHeader: %v\nCookies: %v\n", this.method, this.url, this.request.Header, this.request.Cookies())
+		fmt.Printf("Request: %s %s\nHeader: %v\nCookies: %v\n", this.method, this.request.URL, this.request.Header, this.request.Cookies())
 		fmt.Printf("Body: %s\n", data)
 		fmt.Printf("-------------------------------------------------------------------\n")
 	}
 }
 
 // End 执行请求并返回响应对象、响应内容字符串以及错误
+// 响应内容会根据 BOM、Content-Type 与 HTML meta 检测字符集并转换为 UTF-8
 func (this *Request) End() (*http.Response, string, error) {
 	resp, bodyByte, err := this.EndByte()
 	if err != nil {
 		return resp, "", err
 	}
-	return resp, string(bodyByte), nil
+	text, err := this.decodeText(resp, bodyByte)
+	if err != nil {
+		return resp, "", err
+	}
+	return resp, string(text), nil
 }
 
 // EndByte 执行请求并返回响应对象、响应内容字节数组以及错误
-// 自动处理 gzip 压缩
+// 根据 Content-Encoding 自动解码，支持 gzip、deflate、br、zstd 及通过 HttpClient.SetDecoder 注册的编码，其他编码原样返回并保留 Content-Encoding 响应头
 func (this *Request) EndByte() (*http.Response, []byte, error) {
 	if this.err != nil {
 		return nil, nil, this.err
@@ -191,75 +550,46 @@ func (this *Request) EndByte() (*http.Response, []byte, error) {
 		_ = this.response.Body.Close()
 	}()
 
-	var (
-		bodyByte []byte
-		err      error
-	)
-
-	encoding := strings.ToLower(this.response.Header.Get("Content-Encoding"))
-	if strings.Contains(encoding, "gzip") {
-		var gzReader *gzip.Reader
-		gzReader, err = gzip.NewReader(this.response.Body)
-		if err != nil {
-			return this.response, nil, errors.New("gzip decode failed:" + err.Error())
-		}
-		defer gzReader.Close()
+	this.wrapDownload(this.response, 0)
+	reader, err := this.httpc.decodeBody(this.response)
+	if err != nil {
+		return this.response, nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
 
-		var buf bytes.Buffer
-		if _, err = io.Copy(&buf, gzReader); err != nil {
-			return this.response, nil, errors.New("gzip read failed:" + err.Error())
-		}
-		bodyByte = buf.Bytes()
-	} else {
-		bodyByte, err = io.ReadAll(this.response.Body)
-		if err != nil {
-			return this.response, nil, errors.New("read body failed:" + err.Error())
-		}
+	bodyByte, err := io.ReadAll(reader)
+	if err != nil {
+		return this.response, nil, errors.New("read body failed:" + err.Error())
 	}
 
 	return this.response, bodyByte, nil
 }
 
 // EndFile 将响应体保存为文件
-// savePath 为目录路径，saveFileName 可为空，自动根据 URL 获取文件名
+// savePath 为目录路径，saveFileName 可为空，自动根据 Content-Disposition 或 URL 获取文件名
+// 数据先写入 "文件名.part"，完成后原子地重命名为目标文件，写入失败时删除临时文件；需要断点续传时请使用 Download
 func (this *Request) EndFile(savePath, saveFileName string) (*http.Response, error) {
 	if this.err != nil {
 		return nil, this.err
 	}
 
-	if this.response.StatusCode != http.StatusOK {
-		return this.response, errors.New("failed to write: server responded with non-200 status")
-	}
-
 	defer func() {
 		_ = this.response.Body.Close()
 	}()
 
-	if saveFileName == "" {
-		u, err := url.Parse(this.request.URL.String())
-		if err == nil {
-			parts := strings.Split(strings.Trim(u.Path, "/"), "/")
-			if len(parts) > 0 {
-				saveFileName = parts[len(parts)-1]
-			}
-		}
-		if saveFileName == "" {
-			saveFileName = "download.tmp"
-		}
+	if this.response.StatusCode != http.StatusOK && this.response.StatusCode != http.StatusPartialContent {
+		return this.response, errors.New("failed to write: server responded with non-200 status")
 	}
 
-	destPath := filepath.Join(savePath, saveFileName)
-	file, err := os.Create(destPath)
-	if err != nil {
-		return this.response, err
+	if saveFileName == "" {
+		saveFileName = this.responseFileName(this.response, this.request.URL.String())
 	}
-	defer func() {
-		_ = file.Close()
-	}()
-	_, err = io.Copy(file, this.response.Body)
+
+	destPath, err := this.resolveCollision(filepath.Join(savePath, saveFileName))
 	if err != nil {
 		return this.response, err
 	}
-
-	return this.response, nil
+	return this.response, this.saveFile(destPath, destPath, 0, false)
 }
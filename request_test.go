@@ -0,0 +1,167 @@
+package httpc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Albert-Zhan/httpc/body"
+)
+
+func TestRequestBaseUrlResolvedAtSend(t *testing.T) {
+	a := NewHttpClient().SetBaseUrl("http://a.example.com")
+	b := NewHttpClient().SetBaseUrl("http://b.example.com/v1")
+
+	session := NewSession(b)
+	tests := []struct {
+		name  string
+		setup func() *Request
+		want  string
+	}{
+		{"client base", func() *Request { return NewRequest(a).SetUrl("/x") }, "http://a.example.com/x"},
+		{"set client", func() *Request { return NewRequest(a).SetClient(b).SetUrl("/x") }, "http://b.example.com/v1/x"},
+		{"base changed after build", func() *Request {
+			c := NewHttpClient()
+			req := NewRequest(c).SetUrl("/x")
+			c.SetBaseUrl("http://c.example.com")
+			return req
+		}, "http://c.example.com/x"},
+		{"session falls back to client", func() *Request { return session.NewRequest().SetUrl("/x") }, "http://b.example.com/v1/x"},
+		{"session base changed after build", func() *Request {
+			s := NewSession(b)
+			req := s.NewRequest().SetUrl("/x")
+			s.SetBaseUrl("http://s.example.com")
+			return req
+		}, "http://s.example.com/x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.setup().requestUrl()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	tests := []struct {
+		rawUrl  string
+		params  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"/users/{id}", map[string]string{"id": "1"}, "/users/1", false},
+		{"/files/{name}", map[string]string{"name": "a/b"}, "/files/a%2Fb", false},
+		{"/q/{v}", map[string]string{"v": "a b?c#d"}, "/q/a%20b%3Fc%23d", false},
+		{"/{a}/{b}", map[string]string{"a": "x", "b": "y"}, "/x/y", false},
+		{"/users/{id}/{tab}", map[string]string{"id": "1"}, "", true},
+		{"/users/{id}", nil, "", true},
+		{"/search?q={id}", map[string]string{"id": "1"}, "/search?q={id}", false},
+		{"/doc#{id}", map[string]string{"id": "1"}, "/doc#{id}", false},
+		{"/users/{id}?q={id}", map[string]string{"id": "1"}, "/users/1?q={id}", false},
+		{"http://example.com/{v}", map[string]string{"v": "é"}, "http://example.com/%C3%A9", false},
+		{"/plain", nil, "/plain", false},
+	}
+	for _, tt := range tests {
+		got, err := expandPath(tt.rawUrl, tt.params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandPath(%q) error = %v, wantErr %v", tt.rawUrl, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandPath(%q) = %q, want %q", tt.rawUrl, got, tt.want)
+		}
+	}
+}
+
+func TestJoinUrl(t *testing.T) {
+	tests := []struct {
+		base   string
+		rawUrl string
+		want   string
+	}{
+		{"", "/x", "/x"},
+		{"http://a.com", "", "http://a.com"},
+		{"http://a.com", "/x", "http://a.com/x"},
+		{"http://a.com/", "x", "http://a.com/x"},
+		{"http://a.com/v1", "/users", "http://a.com/v1/users"},
+		{"http://a.com/v1/", "/users", "http://a.com/v1/users"},
+		{"http://a.com", "https://b.com/y", "https://b.com/y"},
+		{"http://a.com/v1?key=k", "/users", "http://a.com/v1/users?key=k"},
+		{"http://a.com/v1?key=k", "/users?page=2", "http://a.com/v1/users?key=k&page=2"},
+		{"http://a.com/v1", "/users?page=2", "http://a.com/v1/users?page=2"},
+		{"http://a.com/v1?key=k", "?page=2", "http://a.com/v1?key=k&page=2"},
+		{"http://a.com/v1?key=k", "/doc#top", "http://a.com/v1/doc?key=k#top"},
+		{"http://a.com/v1#base", "/doc", "http://a.com/v1/doc"},
+	}
+	for _, tt := range tests {
+		if got := joinUrl(tt.base, tt.rawUrl); got != tt.want {
+			t.Errorf("joinUrl(%q, %q) = %q, want %q", tt.base, tt.rawUrl, got, tt.want)
+		}
+	}
+}
+
+func TestRequestEncodesBodyOncePerAttempt(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			t.Errorf("body %q does not match Content-Length %d: %v", data, r.ContentLength, err)
+		}
+		mu.Lock()
+		received = append(received, string(data))
+		n := len(received)
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		body func(encode func(v any) ([]byte, error)) body.Body
+	}{
+		{"json", func(encode func(v any) ([]byte, error)) body.Body { return body.NewJson(nil).SetEncoder(encode) }},
+		{"xml", func(encode func(v any) ([]byte, error)) body.Body { return body.NewXml(nil).SetEncoder(encode) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			received = nil
+			mu.Unlock()
+			calls := 0
+			// 每次编码的结果长度不同，多次编码会导致 Content-Length 与请求体不一致
+			encode := func(v any) ([]byte, error) {
+				calls++
+				return []byte(strings.Repeat("x", calls) + strconv.Itoa(calls)), nil
+			}
+			policy := NewRetryPolicy().SetBackoff(time.Millisecond, time.Millisecond)
+			_, _, err := NewRequest(NewHttpClient()).SetMethod("PUT").SetUrl(srv.URL).SetRetry(policy).
+				SetDebug(true).SetBody(tt.body(encode)).Send().End()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls != 2 {
+				t.Errorf("encoder called %d times for 2 attempts", calls)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != 2 || received[0] != "x1" || received[1] != "xx2" {
+				t.Errorf("server received %q", received)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing 记录一次请求各阶段的耗时
+// 连接被复用时 DNS、Connect、TLS 均为 0
+type Timing struct {
+	// DNS 域名解析耗时
+	DNS time.Duration
+	// Connect TCP 建立连接耗时
+	Connect time.Duration
+	// TLS TLS 握手耗时
+	TLS time.Duration
+	// FirstByte 从开始获取连接到收到响应首字节的耗时
+	FirstByte time.Duration
+	// Total 从发送请求到读取完响应体的总耗时，包含重试等待时间
+	Total time.Duration
+}
+
+// Response 封装了 HTTP 响应及已读取的响应体
+// 由 Request.Do 返回，提供常用的解码与查询方法
+type Response struct {
+	raw    *http.Response
+	body   []byte
+	text   []byte
+	timing Timing
+}
+
+// Do 发送请求并读取完整的响应体，返回 Response 对象
+// 可选传入 context，用于控制请求超时或取消
+// 响应体的读取与 EndByte 相同，会自动处理压缩；字符集的处理与 End 相同
+func (this *Request) Do(ctxs ...context.Context) (*Response, error) {
+	resp, bodyByte, err := this.Send(ctxs...).EndByte()
+	if resp == nil {
+		return nil, err
+	}
+	timing := this.trace.timing()
+	timing.Total = time.Since(this.start)
+	response := &Response{raw: resp, body: bodyByte, text: bodyByte, timing: timing}
+	if err == nil && !this.rawCharset {
+		response.text, err = this.decodeText(resp, bodyByte)
+	}
+	return response, err
+}
+
+// Raw 返回原始的 http.Response，其响应体已被读取并关闭
+func (this *Response) Raw() *http.Response {
+	return this.raw
+}
+
+// StatusCode 返回响应状态码
+func (this *Response) StatusCode() int {
+	return this.raw.StatusCode
+}
+
+// IsSuccess 判断响应状态码是否为 2xx
+func (this *Response) IsSuccess() bool {
+	return this.raw.StatusCode >= 200 && this.raw.StatusCode < 300
+}
+
+// Header 返回响应头
+func (this *Response) Header() http.Header {
+	return this.raw.Header
+}
+
+// Cookies 返回响应中通过 Set-Cookie 设置的 Cookie
+func (this *Response) Cookies() []*http.Cookie {
+	return this.raw.Cookies()
+}
+
+// Bytes 返回未经字符集转换的原始响应体字节数组
+func (this *Response) Bytes() []byte {
+	return this.body
+}
+
+// Text 返回转换为 UTF-8 后的响应体字符串
+func (this *Response) Text() string {
+	return string(this.text)
+}
+
+// JSON 将转换为 UTF-8 后的响应体按 JSON 格式解码到 v 中
+func (this *Response) JSON(v any) error {
+	return json.Unmarshal(this.text, v)
+}
+
+// XML 将响应体按 XML 格式解码到 v 中
+// 已转换为 UTF-8 的内容会忽略 XML 声明中的编码，否则按声明的编码进行转换
+func (this *Response) XML(v any) error {
+	if bytes.Equal(this.text, this.body) {
+		decoder := xml.NewDecoder(bytes.NewReader(this.body))
+		decoder.CharsetReader = xmlCharsetReader
+		return decoder.Decode(v)
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(this.text))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder.Decode(v)
+}
+
+// Timing 返回请求各阶段的耗时
+func (this *Response) Timing() Timing {
+	return this.timing
+}
+
+// requestTrace 通过 httptrace 记录请求各阶段的时间点
+type requestTrace struct {
+	mu           sync.Mutex
+	getConn      time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+// withContext 返回挂载了 httptrace 回调的 context
+func (t *requestTrace) withContext(ctx context.Context) context.Context {
+	mark := func(p *time.Time) {
+		t.mu.Lock()
+		*p = time.Now()
+		t.mu.Unlock()
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn:              func(string) { mark(&t.getConn) },
+		DNSStart:             func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark(&t.tlsDone) },
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+	})
+}
+
+// timing 计算各阶段耗时
+func (t *requestTrace) timing() Timing {
+	if t == nil {
+		return Timing{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := func(start, end time.Time) time.Duration {
+		if start.IsZero() || end.IsZero() || end.Before(start) {
+			return 0
+		}
+		return end.Sub(start)
+	}
+	return Timing{
+		DNS:       span(t.dnsStart, t.dnsDone),
+		Connect:   span(t.connectStart, t.connectDone),
+		TLS:       span(t.tlsStart, t.tlsDone),
+		FirstByte: span(t.getConn, t.firstByte),
+	}
+}
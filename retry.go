@@ -0,0 +1,270 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryAttempt 描述一次请求尝试的结果，用于重试钩子回调
+type RetryAttempt struct {
+	// Attempt 当前尝试次数，从 1 开始
+	Attempt int
+	// Request 本次尝试发送的请求
+	Request *http.Request
+	// Response 本次尝试得到的响应，发生错误时可能为 nil
+	Response *http.Response
+	// Err 本次尝试发生的错误
+	Err error
+	// Retry 是否将进行下一次重试
+	Retry bool
+	// Wait 下一次重试前的等待时间，不重试时为 0
+	Wait time.Duration
+}
+
+// RetryPolicy 定义请求失败后的自动重试策略
+// 包括最大尝试次数、指数退避与抖动、可重试的请求方法、状态码与错误类型以及 Retry-After 处理
+type RetryPolicy struct {
+	maxAttempts     int
+	methods         map[string]bool
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+	jitter          float64
+	statusCodes     map[int]bool
+	retryableErrors []error
+	retryAfter      bool
+	retryIf         func(resp *http.Response, err error) bool
+	hook            func(a RetryAttempt)
+}
+
+// NewRetryPolicy 创建一个默认配置的重试策略
+// 默认最多尝试 3 次，退避时间 200ms 起步、最长 10s，抖动系数 0.2
+// 只重试 GET、HEAD、OPTIONS、PUT、DELETE 请求与携带 Idempotency-Key 的请求，
+// 对 429、500、502、503、504 状态码与常见网络错误进行重试，并遵循 Retry-After
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		maxAttempts: 3,
+		minBackoff:  200 * time.Millisecond,
+		maxBackoff:  10 * time.Second,
+		jitter:      0.2,
+		statusCodes: map[int]bool{
+			http.StatusTooManyRequests:     true,
+			http.StatusInternalServerError: true,
+			http.StatusBadGateway:          true,
+			http.StatusServiceUnavailable:  true,
+			http.StatusGatewayTimeout:      true,
+		},
+		retryAfter: true,
+		methods: map[string]bool{
+			http.MethodGet:     true,
+			http.MethodHead:    true,
+			http.MethodOptions: true,
+			http.MethodPut:     true,
+			http.MethodDelete:  true,
+		},
+	}
+}
+
+// SetMaxAttempts 设置最大尝试次数（包含第一次请求），小于 1 时按 1 处理
+func (this *RetryPolicy) SetMaxAttempts(n int) *RetryPolicy {
+	if n < 1 {
+		n = 1
+	}
+	this.maxAttempts = n
+	return this
+}
+
+// SetBackoff 设置指数退避的初始等待时间与最大等待时间
+// 第 n 次重试的等待时间为 min * 2^(n-1)，且不超过 max
+func (this *RetryPolicy) SetBackoff(min, max time.Duration) *RetryPolicy {
+	if max < min {
+		max = min
+	}
+	this.minBackoff = min
+	this.maxBackoff = max
+	return this
+}
+
+// SetJitter 设置退避时间的随机抖动系数，取值范围 [0, 1]
+// 例如 0.2 表示在计算出的等待时间上下浮动 20%
+func (this *RetryPolicy) SetJitter(f float64) *RetryPolicy {
+	this.jitter = math.Max(0, math.Min(1, f))
+	return this
+}
+
+// SetMethods 设置可以重试的请求方法，会替换默认的方法列表
+// POST、PATCH 等非幂等请求重试时可能被服务端重复处理，确认服务端可以去重后再加入；
+// 携带 Idempotency-Key 请求头的请求不受此限制
+func (this *RetryPolicy) SetMethods(methods ...string) *RetryPolicy {
+	this.methods = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		this.methods[strings.ToUpper(method)] = true
+	}
+	return this
+}
+
+// SetStatusCodes 设置需要重试的响应状态码，会替换默认的状态码列表
+func (this *RetryPolicy) SetStatusCodes(codes ...int) *RetryPolicy {
+	this.statusCodes = make(map[int]bool, len(codes))
+	for _, code := range codes {
+		this.statusCodes[code] = true
+	}
+	return this
+}
+
+// SetRetryableErrors 追加可重试的错误类型，通过 errors.Is 进行匹配
+// 默认已包含超时、连接被重置、连接被拒绝以及连接意外断开等网络错误
+func (this *RetryPolicy) SetRetryableErrors(errs ...error) *RetryPolicy {
+	this.retryableErrors = append(this.retryableErrors, errs...)
+	return this
+}
+
+// SetRetryAfter 设置是否遵循响应头中的 Retry-After
+// 开启后若 Retry-After 指定的时间大于退避时间，则以 Retry-After 为准
+func (this *RetryPolicy) SetRetryAfter(b bool) *RetryPolicy {
+	this.retryAfter = b
+	return this
+}
+
+// SetRetryIf 设置自定义的重试判断函数，会替代默认的状态码与错误判断，请求方法仍需满足 SetMethods
+func (this *RetryPolicy) SetRetryIf(f func(resp *http.Response, err error) bool) *RetryPolicy {
+	this.retryIf = f
+	return this
+}
+
+// SetHook 设置每次尝试结束后的回调函数，可用于记录日志或统计
+func (this *RetryPolicy) SetHook(f func(a RetryAttempt)) *RetryPolicy {
+	this.hook = f
+	return this
+}
+
+// shouldRetry 判断本次尝试的结果是否需要重试
+// 熔断器打开导致的失败与不可重试方法的请求不会重试
+func (this *RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || !this.retryable(req) {
+		return false
+	}
+	if this.retryIf != nil {
+		return this.retryIf(resp, err)
+	}
+	if err != nil {
+		return this.isRetryableError(err)
+	}
+	return resp != nil && this.statusCodes[resp.StatusCode]
+}
+
+// retryable 判断请求方法是否允许重试，携带 Idempotency-Key 或 X-Idempotency-Key 的请求视为幂等
+func (this *RetryPolicy) retryable(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if this.methods[method] {
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// isRetryableError 判断错误是否属于可重试的类型
+func (this *RetryPolicy) isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	for _, target := range this.retryableErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// backoff 计算第 attempt 次尝试失败后的等待时间
+func (this *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	wait := this.minBackoff
+	for i := 1; i < attempt && wait < this.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > this.maxBackoff {
+		wait = this.maxBackoff
+	}
+	if this.jitter > 0 && wait > 0 {
+		delta := float64(wait) * this.jitter
+		wait = time.Duration(float64(wait) - delta + rand.Float64()*2*delta)
+	}
+	if this.retryAfter && resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && after > wait {
+			wait = after
+		}
+	}
+	return wait
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数与 HTTP 日期两种格式
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleepContext 等待指定的时间，context 被取消时提前返回错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// drainBody 读取并关闭响应体，使底层连接可以被复用
+func drainBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,174 @@
+package httpc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Albert-Zhan/httpc/body"
+)
+
+func TestRetryMethods(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header string
+		policy func(p *RetryPolicy)
+		want   int32
+	}{
+		{name: "get", method: "GET", want: 3},
+		{name: "put", method: "PUT", want: 3},
+		{name: "post", method: "POST", want: 1},
+		{name: "patch", method: "PATCH", want: 1},
+		{name: "post with idempotency key", method: "POST", header: "Idempotency-Key", want: 3},
+		{name: "post opted in", method: "POST", policy: func(p *RetryPolicy) { p.SetMethods("post") }, want: 3},
+		{name: "retry if keeps method check", method: "POST", policy: func(p *RetryPolicy) {
+			p.SetRetryIf(func(resp *http.Response, err error) bool { return true })
+		}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer srv.Close()
+
+			policy := NewRetryPolicy().SetBackoff(time.Millisecond, time.Millisecond)
+			if tt.policy != nil {
+				tt.policy(policy)
+			}
+			req := NewRequest(NewHttpClient()).SetMethod(tt.method).SetUrl(srv.URL).SetRetry(policy).
+				SetBody(body.NewJson(map[string]int{"n": 1}))
+			if tt.header != "" {
+				req.SetHeader(tt.header, "k1")
+			}
+			if _, _, err := req.Send().End(); err != nil {
+				t.Fatal(err)
+			}
+			if n := hits.Load(); n != tt.want {
+				t.Errorf("server received %d requests, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter string
+		honour     bool
+		want       time.Duration
+	}{
+		{name: "first", attempt: 1, honour: true, want: 100 * time.Millisecond},
+		{name: "doubles", attempt: 3, honour: true, want: 400 * time.Millisecond},
+		{name: "capped", attempt: 10, honour: true, want: time.Second},
+		{name: "retry after longer", attempt: 1, retryAfter: "3", honour: true, want: 3 * time.Second},
+		{name: "retry after shorter", attempt: 3, retryAfter: "0", honour: true, want: 400 * time.Millisecond},
+		{name: "retry after ignored", attempt: 1, retryAfter: "3", honour: false, want: 100 * time.Millisecond},
+		{name: "retry after invalid", attempt: 1, retryAfter: "soon", honour: true, want: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewRetryPolicy().SetBackoff(100*time.Millisecond, time.Second).SetJitter(0).SetRetryAfter(tt.honour)
+			resp := &http.Response{Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			if got := policy.backoff(tt.attempt, resp); got != tt.want {
+				t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+
+	policy := NewRetryPolicy().SetBackoff(100*time.Millisecond, time.Second).SetJitter(0.5)
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(1, nil); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("jittered backoff %v out of range", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", false, 0, 0},
+		{"5", true, 5 * time.Second, 5 * time.Second},
+		{"-1", false, 0, 0},
+		{"later", false, 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), true, 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), true, 0, 0},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if ok != tt.ok || got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, %v", tt.value, got, ok)
+		}
+	}
+}
+
+func TestRetryHookAndBodyRebuild(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(data))
+		n := len(bodies)
+		mu.Unlock()
+		if n < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var attempts []RetryAttempt
+	policy := NewRetryPolicy().SetMaxAttempts(5).SetBackoff(time.Millisecond, time.Millisecond).SetJitter(0).
+		SetHook(func(a RetryAttempt) {
+			attempts = append(attempts, a)
+		})
+	resp, data, err := NewRequest(NewHttpClient()).SetMethod("PUT").SetUrl(srv.URL).SetRetry(policy).
+		SetBody(body.NewJson(map[string]string{"name": "httpc"})).Send().End()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || data != "ok" {
+		t.Fatalf("got %d %q", resp.StatusCode, data)
+	}
+
+	for i, b := range bodies {
+		if b != `{"name":"httpc"}` {
+			t.Errorf("attempt %d sent body %q", i+1, b)
+		}
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("hook called %d times, want 3", len(attempts))
+	}
+	for i, a := range attempts {
+		last := i == len(attempts)-1
+		if a.Attempt != i+1 || a.Retry == last || a.Request == nil || a.Response == nil {
+			t.Errorf("attempt %d: %+v", i+1, a)
+		}
+		if !last && a.Wait != time.Millisecond {
+			t.Errorf("attempt %d wait %v", i+1, a.Wait)
+		}
+		if last && a.Wait != 0 {
+			t.Errorf("last attempt wait %v", a.Wait)
+		}
+	}
+}
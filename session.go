@@ -0,0 +1,271 @@
+package httpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// sessionVersion 为会话快照的格式版本
+const sessionVersion = 1
+
+// sessionFile 为会话快照的 JSON 结构
+type sessionFile struct {
+	Version int             `json:"version"`
+	BaseUrl string          `json:"base_url,omitempty"`
+	Header  headerList      `json:"header,omitempty"`
+	Param   url.Values      `json:"param,omitempty"`
+	Auth    string          `json:"auth,omitempty"`
+	Cookies json.RawMessage `json:"cookies"`
+}
+
+// Session 在 HttpClient 的基础上保存默认头信息、查询参数、基础 URL、认证信息与 CookieJar
+// 通过 Session.NewRequest 创建的请求会继承这些默认值，请求上的设置优先于默认值
+// Session 使用独立的 http.Client 与 CookieJar，与原 HttpClient 共享底层 http.Transport、限速、熔断与缓存，
+// 缓存按 Authorization 与 Cookie 区分，不同会话的认证响应不会互相返回
+type Session struct {
+	mu      sync.RWMutex
+	client  *HttpClient
+	jar     *CookieJar
+	baseUrl string
+	header  headerList
+	param   url.Values
+	auth    string
+}
+
+// NewSession 基于 client 创建一个会话，会话拥有一个新的 CookieJar
+func NewSession(client *HttpClient) *Session {
+	session := &Session{
+		jar:   NewCookieJar(),
+		param: url.Values{},
+	}
+	fork := *client
+	httpClient := *client.client
+	httpClient.Jar = sessionJar{session: session}
+	fork.client = &httpClient
+	fork.middlewares = append([]Middleware(nil), client.middlewares...)
+	fork.decoders = make(map[string]Decoder, len(client.decoders))
+	for k, v := range client.decoders {
+		fork.decoders[k] = v
+	}
+	session.client = &fork
+	return session
+}
+
+// sessionJar 为会话的 http.Client 使用的 CookieJar，转发到会话当前的 CookieJar
+// 替换 CookieJar 时只需修改 Session 中的字段，http.Client 在创建后不再被修改
+type sessionJar struct {
+	session *Session
+}
+
+func (this sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	this.session.CookieJar().SetCookies(u, cookies)
+}
+
+func (this sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	return this.session.CookieJar().Cookies(u)
+}
+
+// Client 返回会话使用的 HttpClient，可用于为会话单独注册中间件或设置重试策略
+func (this *Session) Client() *HttpClient {
+	return this.client
+}
+
+// CookieJar 返回会话使用的 CookieJar
+func (this *Session) CookieJar() *CookieJar {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.jar
+}
+
+// SetCookieJar 替换会话使用的 CookieJar，例如使用 NewFileCookieJar 持久化的 CookieJar
+// 可以在请求进行中调用，之后发出的请求使用新的 CookieJar
+func (this *Session) SetCookieJar(j *CookieJar) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.jar = j
+	return this
+}
+
+// SetBaseUrl 设置基础 URL，请求通过 SetUrl 设置的相对地址会拼接在基础 URL 之后
+// 未设置时使用 HttpClient 的基础 URL，基础 URL 在请求发送时读取，修改后对已创建的请求同样生效
+func (this *Session) SetBaseUrl(baseUrl string) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.baseUrl = baseUrl
+	return this
+}
+
+// SetHeader 设置默认请求头，例如 User-Agent、Referer，已存在同名请求头时在原位置替换
+func (this *Session) SetHeader(name, value string) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.header = this.header.set(name, value)
+	return this
+}
+
+// AddHeader 追加一个默认请求头，同名请求头可以添加多次
+func (this *Session) AddHeader(name, value string) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.header = this.header.add(name, value)
+	return this
+}
+
+// DelHeader 删除默认请求头
+func (this *Session) DelHeader(name string) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.header = this.header.del(name)
+	return this
+}
+
+// SetParam 设置默认查询参数，请求通过 SetParam 设置同名参数时以请求上的为准
+func (this *Session) SetParam(name, value string) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.param.Set(name, value)
+	return this
+}
+
+// DelParam 删除默认查询参数
+func (this *Session) DelParam(name string) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.param.Del(name)
+	return this
+}
+
+// SetBasicAuth 设置默认的 HTTP Basic Auth 认证
+func (this *Session) SetBasicAuth(username, password string) *Session {
+	return this.SetAuth("Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+}
+
+// SetBearerToken 设置默认的 Bearer Token 认证
+func (this *Session) SetBearerToken(token string) *Session {
+	return this.SetAuth("Bearer " + token)
+}
+
+// SetAuth 设置默认的 Authorization 请求头，传入空字符串表示清除认证信息
+func (this *Session) SetAuth(value string) *Session {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.auth = value
+	return this
+}
+
+// NewRequest 创建一个继承会话默认值的 Request
+func (this *Session) NewRequest() *Request {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	req := NewRequest(this.client)
+	req.session = this
+	req.header = this.header.clone()
+	if this.auth != "" {
+		req.header = req.header.set("Authorization", this.auth)
+	}
+	if len(this.param) > 0 {
+		req.baseParam = make(url.Values, len(this.param))
+		for k, v := range this.param {
+			req.baseParam[k] = append([]string(nil), v...)
+		}
+	}
+	return req
+}
+
+// Save 将会话的基础 URL、默认头信息、查询参数、认证信息与全部未过期的 Cookie（包括会话 Cookie）以 JSON 格式写入 w
+func (this *Session) Save(w io.Writer) error {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	var cookies bytes.Buffer
+	this.jar.mu.Lock()
+	err := this.jar.save(&cookies, true)
+	this.jar.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sessionFile{
+		Version: sessionVersion,
+		BaseUrl: this.baseUrl,
+		Header:  this.header,
+		Param:   this.param,
+		Auth:    this.auth,
+		Cookies: cookies.Bytes(),
+	})
+}
+
+// Load 从 r 中读取 Save 写入的快照并恢复会话，原有的默认值与 Cookie 会被替换
+// 快照解析失败时返回错误，会话保持不变
+func (this *Session) Load(r io.Reader) error {
+	var file sessionFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+	if file.Version != sessionVersion {
+		return errors.New("session: unsupported file version")
+	}
+	cookies := NewCookieJar()
+	if len(file.Cookies) > 0 {
+		if err := cookies.Load(bytes.NewReader(file.Cookies)); err != nil {
+			return err
+		}
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.jar.replace(cookies)
+	this.baseUrl = file.BaseUrl
+	this.header = file.Header
+	this.param = file.Param
+	if this.param == nil {
+		this.param = url.Values{}
+	}
+	this.auth = file.Auth
+	return nil
+}
+
+// SaveFile 将会话快照保存到文件，先写入临时文件再重命名
+// 快照中包含认证信息与 Cookie，文件权限为 0600
+func (this *Session) SaveFile(filename string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	err = this.Save(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+	}
+	return err
+}
+
+// LoadFile 从 SaveFile 保存的文件中恢复会话
+func (this *Session) LoadFile(filename string) error {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fd.Close()
+	}()
+	return this.Load(fd)
+}
@@ -0,0 +1,111 @@
+package httpc
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSessionLoadKeepsStateOnError(t *testing.T) {
+	u, _ := url.Parse("http://example.com/")
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+	jar, err := NewFileCookieJar(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession(NewHttpClient()).SetCookieJar(jar).SetHeader("X-Token", "old")
+	jar.SetCookies(u, []*http.Cookie{{Name: "sid", Value: "1", MaxAge: 3600}})
+	before, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []string{
+		`{"version":1,"header":[{"name":"X-Token","value":"new"}],"cookies":{"version":99,"cookies":[]}}`,
+		`{"version":1,"header":[{"name":"X-Token","value":"new"}],"cookies":{"version":1,"cookies":"bad"}}`,
+		`{"version":2,"cookies":null}`,
+	}
+	for _, data := range tests {
+		if err := s.Load(strings.NewReader(data)); err == nil {
+			t.Errorf("Load(%s) succeeded", data)
+		}
+		if cookies := s.CookieJar().Cookies(u); len(cookies) != 1 || cookies[0].Value != "1" {
+			t.Errorf("cookies after failed load: %v", cookies)
+		}
+		header := http.Header{}
+		s.NewRequest().header.apply(header)
+		if got := header.Get("X-Token"); got != "old" {
+			t.Errorf("header after failed load: %q", got)
+		}
+		if after, _ := os.ReadFile(filename); !bytes.Equal(before, after) {
+			t.Errorf("cookie file rewritten after failed load:\n%s", after)
+		}
+	}
+
+	var snapshot bytes.Buffer
+	other := NewSession(NewHttpClient())
+	other.CookieJar().SetCookies(u, []*http.Cookie{{Name: "sid", Value: "2", MaxAge: 3600}})
+	if err = other.Save(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Load(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if cookies := s.CookieJar().Cookies(u); len(cookies) != 1 || cookies[0].Value != "2" {
+		t.Errorf("cookies after load: %v", cookies)
+	}
+	if s.CookieJar() != jar {
+		t.Error("load replaced the file-backed jar")
+	}
+}
+
+func TestSessionSetCookieJarDuringRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "n", Value: "1"})
+	}))
+	defer srv.Close()
+
+	s := NewSession(NewHttpClient())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, _, err := s.NewRequest().SetUrl(srv.URL).Send().EndByte(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			s.SetCookieJar(NewCookieJar())
+		}
+	}
+	jar := NewCookieJar()
+	s.SetCookieJar(jar)
+
+	if _, _, err := s.NewRequest().SetUrl(srv.URL).Send().EndByte(); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(srv.URL)
+	if len(jar.Cookies(u)) != 1 {
+		t.Error("response cookie not stored in the replaced jar")
+	}
+}